@@ -14,7 +14,7 @@ func dataOpennebulaDatastore() *schema.Resource {
 	return &schema.Resource{
 		ReadContext: datasourceOpennebulaDatastoreRead,
 
-		Schema: map[string]*schema.Schema{
+		Schema: mergeSchemas(map[string]*schema.Schema{
 			"id": {
 				Type:        schema.TypeInt,
 				Optional:    true,
@@ -26,8 +26,14 @@ func dataOpennebulaDatastore() *schema.Resource {
 				Optional:    true,
 				Description: "Name of the datastore",
 			},
+			"min_free_mb": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "Minimum free capacity of the datastore in MB",
+			},
 			"tags": tagsSchema(),
 		},
+			datastoreCapacityFields()),
 	}
 }
 
@@ -44,6 +50,7 @@ func datastoreFilter(d *schema.ResourceData, meta interface{}) (*datastoreSc.Dat
 	// filter datastores with user defined criterias
 	id := d.Get("id")
 	name, nameOk := d.GetOk("name")
+	minFreeMB, minFreeMBOk := d.GetOk("min_free_mb")
 	tagsInterface, tagsOk := d.GetOk("tags")
 	tags := tagsInterface.(map[string]interface{})
 
@@ -58,6 +65,10 @@ func datastoreFilter(d *schema.ResourceData, meta interface{}) (*datastoreSc.Dat
 			continue
 		}
 
+		if minFreeMBOk && datastore.FreeMB < minFreeMB.(int) {
+			continue
+		}
+
 		if tagsOk && !matchTags(datastore.Template.Template, tags) {
 			continue
 		}
@@ -69,7 +80,18 @@ func datastoreFilter(d *schema.ResourceData, meta interface{}) (*datastoreSc.Dat
 	if len(match) == 0 {
 		return nil, fmt.Errorf("no datastore match the constraints")
 	} else if len(match) > 1 {
-		return nil, fmt.Errorf("several datastores match the constraints")
+		if !minFreeMBOk {
+			return nil, fmt.Errorf("several datastores match the constraints")
+		}
+
+		// pick the datastore with the most free space
+		mostFree := match[0]
+		for _, datastore := range match[1:] {
+			if datastore.FreeMB > mostFree.FreeMB {
+				mostFree = datastore
+			}
+		}
+		return mostFree, nil
 	}
 
 	return match[0], nil
@@ -94,6 +116,16 @@ func datasourceOpennebulaDatastoreRead(ctx context.Context, d *schema.ResourceDa
 	d.SetId(strconv.FormatInt(int64(datastore.ID), 10))
 	d.Set("name", datastore.Name)
 
+	err = flattenDatastoreCapacity(d, datastore)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "setting attribute failed",
+			Detail:   fmt.Sprintf("Datastore (ID: %d): %s", datastore.ID, err),
+		})
+		return diags
+	}
+
 	if len(tplPairs) > 0 {
 		err := d.Set("tags", tplPairs)
 		if err != nil {
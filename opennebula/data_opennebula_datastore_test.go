@@ -0,0 +1,45 @@
+package opennebula
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDatastoreDataSource(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDatastoreDataSourceCapacity,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.opennebula_datastore.default", "name", "default"),
+					resource.TestCheckResourceAttrSet("data.opennebula_datastore.default", "total_mb"),
+					resource.TestCheckResourceAttrSet("data.opennebula_datastore.default", "free_mb"),
+					resource.TestCheckResourceAttrSet("data.opennebula_datastore.default", "used_mb"),
+					resource.TestCheckResourceAttrSet("data.opennebula_datastore.default", "image_ids.#"),
+				),
+			},
+			{
+				Config:      testAccDatastoreDataSourceNotEnoughSpace,
+				ExpectError: regexp.MustCompile("no datastore match the constraints"),
+			},
+		},
+	})
+}
+
+var testAccDatastoreDataSourceCapacity = `
+data "opennebula_datastore" "default" {
+  id          = 1
+  min_free_mb = 1
+}
+`
+
+var testAccDatastoreDataSourceNotEnoughSpace = `
+data "opennebula_datastore" "default" {
+  id          = 1
+  min_free_mb = 1000000000
+}
+`
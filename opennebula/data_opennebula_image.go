@@ -26,7 +26,8 @@ func dataOpennebulaImage() *schema.Resource {
 				Optional:    true,
 				Description: "Name of the image",
 			},
-			"tags": tagsSchema(),
+			"snapshots": imageSnapshotsSchema(),
+			"tags":      tagsSchema(),
 		},
 	}
 }
@@ -94,6 +95,16 @@ func datasourceOpennebulaImageRead(ctx context.Context, d *schema.ResourceData,
 	d.SetId(strconv.FormatInt(int64(image.ID), 10))
 	d.Set("name", image.Name)
 
+	err = d.Set("snapshots", flattenImageSnapshots(image))
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "setting attribute failed",
+			Detail:   fmt.Sprintf("Image (ID: %d): %s", image.ID, err),
+		})
+		return diags
+	}
+
 	if len(tplPairs) > 0 {
 		err := d.Set("tags", tplPairs)
 		if err != nil {
@@ -3,9 +3,12 @@ package opennebula
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
 
 	appSc "github.com/OpenNebula/one/src/oca/go/src/goca/schemas/marketplaceapp"
+	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
@@ -22,9 +25,79 @@ func dataOpennebulaMarketplaceApp() *schema.Resource {
 				Description: "Id of the appliance",
 			},
 			"name": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				Description:   "Name of the appliance",
+				ConflictsWith: []string{"name_regex"},
+			},
+			"name_regex": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Description:   "Filter appliances by name with a RE2 a regular expression",
+				ConflictsWith: []string{"name"},
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					_, err := regexp.Compile(v.(string))
+					if err != nil {
+						errors = append(errors, fmt.Errorf("%q is not a valid regular expression: %s", k, err))
+					}
+					return
+				},
+			},
+			"market_id": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     -1,
+				Description: "Id of the marketplace hosting the appliance",
+			},
+			"type": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "Type of the appliance: IMAGE, VMTEMPLATE, SERVICE_TEMPLATE",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := v.(string)
+
+					if !contains(value, marketplaceAppType) {
+						errors = append(errors, fmt.Errorf("Type %q must be one of: %s", k, strings.Join(marketplaceAppType, ",")))
+					}
+
+					return
+				},
+			},
+			"publisher": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "Publisher of the appliance",
+			},
+			"version_constraint": {
 				Type:        schema.TypeString,
 				Optional:    true,
-				Description: "Name of the appliance",
+				Description: "Version constraint on the appliance version, i.e. '>= 6.8'",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					_, err := version.NewConstraint(v.(string))
+					if err != nil {
+						errors = append(errors, fmt.Errorf("%q is not a valid version constraint: %s", k, err))
+					}
+					return
+				},
+			},
+			"most_recent": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "If several appliances match, select the most recently registered",
+			},
+			"version": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Version of the appliance",
+			},
+			"reg_time": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Registration time of the appliance",
 			},
 			"tags": tagsSchema(),
 		},
@@ -44,9 +117,36 @@ func applianceFilter(d *schema.ResourceData, meta interface{}) (*appSc.MarketPla
 	// filter appliances
 	id := d.Get("id")
 	name, nameOk := d.GetOk("name")
+	nameRegStr := d.Get("name_regex").(string)
+	appType, typeOk := d.GetOk("type")
+	publisher, publisherOk := d.GetOk("publisher")
 	tagsInterface, tagsOk := d.GetOk("tags")
 	tags := tagsInterface.(map[string]interface{})
 
+	var nameReg *regexp.Regexp
+	if len(nameRegStr) > 0 {
+		nameReg = regexp.MustCompile(nameRegStr)
+	}
+
+	var constraint version.Constraints
+	if constraintStr := d.Get("version_constraint").(string); len(constraintStr) > 0 {
+		constraint, err = version.NewConstraint(constraintStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the appliances only reference their marketplace by name
+	market := ""
+	marketID := d.Get("market_id").(int)
+	if marketID != -1 {
+		marketInfos, err := controller.MarketPlace(marketID).Info(false)
+		if err != nil {
+			return nil, fmt.Errorf("marketplace (ID: %d): %s", marketID, err)
+		}
+		market = marketInfos.Name
+	}
+
 	match := make([]*appSc.MarketPlaceApp, 0, 1)
 	for i, app := range apps.MarketPlaceApps {
 
@@ -58,6 +158,29 @@ func applianceFilter(d *schema.ResourceData, meta interface{}) (*appSc.MarketPla
 			continue
 		}
 
+		if nameReg != nil && !nameReg.MatchString(app.Name) {
+			continue
+		}
+
+		if marketID != -1 && app.MarketPlace != market {
+			continue
+		}
+
+		if typeOk && ApplianceTypeToString(app.Type) != appType {
+			continue
+		}
+
+		if publisherOk {
+			appPublisher, _ := app.Template.GetStr("PUBLISHER")
+			if appPublisher != publisher {
+				continue
+			}
+		}
+
+		if constraint != nil && !appVersionMatches(constraint, app.Version) {
+			continue
+		}
+
 		if tagsOk && !matchTags(app.Template.Template, tags) {
 			continue
 		}
@@ -69,7 +192,17 @@ func applianceFilter(d *schema.ResourceData, meta interface{}) (*appSc.MarketPla
 	if len(match) == 0 {
 		return nil, fmt.Errorf("no appliance match the constraints")
 	} else if len(match) > 1 {
-		return nil, fmt.Errorf("several appliances match the constraints")
+		if !d.Get("most_recent").(bool) {
+			return nil, fmt.Errorf("several appliances match the constraints, set most_recent to select the most recent")
+		}
+
+		mostRecent := match[0]
+		for _, app := range match[1:] {
+			if app.RegTime > mostRecent.RegTime {
+				mostRecent = app
+			}
+		}
+		return mostRecent, nil
 	}
 
 	return match[0], nil
@@ -93,6 +226,12 @@ func datasourceOpennebulaMarketplaceAppRead(ctx context.Context, d *schema.Resou
 
 	d.SetId(strconv.FormatInt(int64(app.ID), 10))
 	d.Set("name", app.Name)
+	d.Set("type", ApplianceTypeToString(app.Type))
+	d.Set("version", app.Version)
+	d.Set("reg_time", app.RegTime)
+
+	publisher, _ := app.Template.GetStr("PUBLISHER")
+	d.Set("publisher", publisher)
 
 	if len(tplPairs) > 0 {
 		err := d.Set("tags", tplPairs)
@@ -108,3 +247,15 @@ func datasourceOpennebulaMarketplaceAppRead(ctx context.Context, d *schema.Resou
 
 	return nil
 }
+
+// appVersionMatches checks the appliance version against the constraint. The
+// appliances versions have a build suffix (e.g. 6.10.0-1-20240514) parsed as a
+// pre-release, which never matches a constraint without pre-release.
+func appVersionMatches(constraint version.Constraints, appVersion string) bool {
+	v, err := version.NewVersion(appVersion)
+	if err != nil {
+		return false
+	}
+
+	return constraint.Check(v.Core())
+}
@@ -0,0 +1,90 @@
+package opennebula
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAppVersionMatches(t *testing.T) {
+	constraint, err := version.NewConstraint(">= 6.8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]bool{
+		"6.10.0":            true,
+		"6.10.0-1-20240514": true,
+		"6.8.0-1":           true,
+		"6.6.0":             false,
+		"6.6.0-1-20230101":  false,
+		"not a version":     false,
+	}
+
+	for appVersion, expected := range cases {
+		if appVersionMatches(constraint, appVersion) != expected {
+			t.Errorf("version %q: expected match to be %t", appVersion, expected)
+		}
+	}
+}
+
+func TestAccMarketplaceAppDataSource(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccMarketplaceAppDataSourceInvalidConstraint,
+				ExpectError: regexp.MustCompile("is not a valid version constraint"),
+			},
+			{
+				Config:      testAccMarketplaceAppDataSourceNoMatch,
+				ExpectError: regexp.MustCompile("no appliance match the constraints"),
+			},
+			{
+				Config:      testAccMarketplaceAppDataSourceSeveral,
+				ExpectError: regexp.MustCompile("several appliances match the constraints"),
+			},
+			{
+				Config: testAccMarketplaceAppDataSourceMostRecent,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("data.opennebula_marketplace_appliance.latest", "name", regexp.MustCompile("^Ttylinux")),
+					resource.TestCheckResourceAttr("data.opennebula_marketplace_appliance.latest", "type", "IMAGE"),
+					resource.TestCheckResourceAttrSet("data.opennebula_marketplace_appliance.latest", "version"),
+					resource.TestCheckResourceAttrSet("data.opennebula_marketplace_appliance.latest", "reg_time"),
+				),
+			},
+		},
+	})
+}
+
+var testAccMarketplaceAppDataSourceInvalidConstraint = `
+data "opennebula_marketplace_appliance" "latest" {
+  name_regex         = "^Ttylinux"
+  version_constraint = "not a constraint"
+}
+`
+
+var testAccMarketplaceAppDataSourceNoMatch = `
+data "opennebula_marketplace_appliance" "latest" {
+  name_regex = "^terra-no-such-appliance$"
+}
+`
+
+var testAccMarketplaceAppDataSourceSeveral = `
+data "opennebula_marketplace_appliance" "latest" {
+  name_regex = "."
+  market_id  = 0
+}
+`
+
+var testAccMarketplaceAppDataSourceMostRecent = `
+data "opennebula_marketplace_appliance" "latest" {
+  name_regex  = "^Ttylinux"
+  market_id   = 0
+  type        = "IMAGE"
+  most_recent = true
+}
+`
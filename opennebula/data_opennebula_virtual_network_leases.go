@@ -0,0 +1,229 @@
+package opennebula
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca"
+	vn "github.com/OpenNebula/one/src/oca/go/src/goca/schemas/virtualnetwork"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+var vnetLeaseOwnerTypes = []string{"VM", "VNET", "VROUTER", "HOLD"}
+
+func dataSourceOpennebulaVirtualNetworkLeases() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceOpennebulaVirtualNetworkLeasesRead,
+		Schema: map[string]*schema.Schema{
+			"virtual_network_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				Description: "ID of the virtual network",
+			},
+			"ar_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Only return the leases of this address range",
+			},
+			"owner_type": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Only return the leases of this owner type: VM, VNET, VROUTER, HOLD",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					value := strings.ToUpper(v.(string))
+
+					if !contains(value, vnetLeaseOwnerTypes) {
+						errors = append(errors, fmt.Errorf("Owner type %q must be one of: %s", k, strings.Join(vnetLeaseOwnerTypes, ",")))
+					}
+
+					return
+				},
+			},
+			"leases": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Leases of the virtual network",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"ar_id": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "ID of the address range of the lease",
+						},
+						"ip": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "IPv4 of the lease",
+						},
+						"ip6": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "IPv6 of the lease",
+						},
+						"ip6_global": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Global IPv6 of the lease",
+						},
+						"ip6_ula": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "ULA IPv6 of the lease",
+						},
+						"mac": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "MAC of the lease",
+						},
+						"owner_type": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Type of the lease owner: VM, VNET, VROUTER, HOLD",
+						},
+						"owner_id": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "ID of the lease owner, -1 for held addresses",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// vnetLeaseOwnerIDs holds the owner attributes of a lease. goca decodes a missing
+// VM, VNET or VROUTER element as 0, so an owner with ID 0 can't be told apart from
+// a missing element: they are decoded again here and left nil when missing.
+type vnetLeaseOwnerIDs struct {
+	MAC     string `xml:"MAC"`
+	VM      *int   `xml:"VM"`
+	VNet    *int   `xml:"VNET"`
+	VRouter *int   `xml:"VROUTER"`
+}
+
+type vnetLeasesOwners struct {
+	Leases []vnetLeaseOwnerIDs `xml:"AR_POOL>AR>LEASES>LEASE"`
+}
+
+// getVnetLeasesOwners returns the owner attributes of the leases of a virtual network, by MAC address
+func getVnetLeasesOwners(controller *goca.Controller, vnetID int) (map[string]vnetLeaseOwnerIDs, error) {
+	response, err := controller.Client.Call("one.vn.info", vnetID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseVnetLeasesOwners(response.Body())
+}
+
+func parseVnetLeasesOwners(body string) (map[string]vnetLeaseOwnerIDs, error) {
+	owners := vnetLeasesOwners{}
+	err := xml.Unmarshal([]byte(body), &owners)
+	if err != nil {
+		return nil, err
+	}
+
+	leases := make(map[string]vnetLeaseOwnerIDs, len(owners.Leases))
+	for _, lease := range owners.Leases {
+		leases[lease.MAC] = lease
+	}
+
+	return leases, nil
+}
+
+// vnetLeaseOwner returns the type and the ID of the object using the lease.
+// OpenNebula only writes the attribute of the actual owner, the VM attribute
+// is set to -1 for the addresses on hold.
+func vnetLeaseOwner(lease vnetLeaseOwnerIDs) (string, int) {
+	switch {
+	case lease.VNet != nil:
+		return "VNET", *lease.VNet
+	case lease.VRouter != nil:
+		return "VROUTER", *lease.VRouter
+	case lease.VM == nil || *lease.VM == -1:
+		return "HOLD", -1
+	default:
+		return "VM", *lease.VM
+	}
+}
+
+func flattenVNetLease(arID string, lease *vn.Lease, owner vnetLeaseOwnerIDs) map[string]interface{} {
+	ownerType, ownerID := vnetLeaseOwner(owner)
+
+	return map[string]interface{}{
+		"ar_id":      arID,
+		"ip":         lease.IP,
+		"ip6":        lease.IP6,
+		"ip6_global": lease.IP6Global,
+		"ip6_ula":    lease.IP6ULA,
+		"mac":        lease.MAC,
+		"owner_type": ownerType,
+		"owner_id":   ownerID,
+	}
+}
+
+func dataSourceOpennebulaVirtualNetworkLeasesRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+	virtualNetworkID := d.Get("virtual_network_id").(int)
+
+	arID, arIDOk := d.GetOk("ar_id")
+	ownerType := strings.ToUpper(d.Get("owner_type").(string))
+
+	virtualNetworkInfo, err := controller.VirtualNetwork(virtualNetworkID).Info(false)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve information about the virtual network",
+			Detail:   fmt.Sprintf("Virtual Network (ID:%d): %s", virtualNetworkID, err),
+		})
+		return diags
+	}
+
+	owners, err := getVnetLeasesOwners(controller, virtualNetworkID)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve the owners of the leases",
+			Detail:   fmt.Sprintf("Virtual Network (ID:%d): %s", virtualNetworkID, err),
+		})
+		return diags
+	}
+
+	leases := make([]interface{}, 0)
+
+	for _, addressRange := range virtualNetworkInfo.ARs {
+		if arIDOk && addressRange.ID != arID {
+			continue
+		}
+
+		for i := range addressRange.Leases {
+			lease := flattenVNetLease(addressRange.ID, &addressRange.Leases[i], owners[addressRange.Leases[i].MAC])
+			if len(ownerType) > 0 && lease["owner_type"] != ownerType {
+				continue
+			}
+			leases = append(leases, lease)
+		}
+	}
+
+	err = d.Set("leases", leases)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to set leases field",
+			Detail:   fmt.Sprintf("Virtual Network (ID:%d): %s", virtualNetworkID, err),
+		})
+		return diags
+	}
+
+	d.SetId(fmt.Sprintf("%d", virtualNetworkID))
+	log.Printf("[INFO] Successfully retrieved leases of the virtual network %d\n", virtualNetworkID)
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package opennebula
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccDataSourceOpennebulaVirtualNetworkLeases(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVirtualNetworkDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceVirtualNetworkLeasesConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.opennebula_virtual_network_leases.all", "leases.#", "2"),
+					resource.TestCheckTypeSetElemNestedAttrs("data.opennebula_virtual_network_leases.all", "leases.*", map[string]string{
+						"ip":         "172.16.120.102",
+						"owner_type": "HOLD",
+						"owner_id":   "-1",
+					}),
+					resource.TestCheckTypeSetElemNestedAttrs("data.opennebula_virtual_network_leases.all", "leases.*", map[string]string{
+						"ip":         "172.16.120.105",
+						"owner_type": "HOLD",
+						"owner_id":   "-1",
+					}),
+					resource.TestCheckResourceAttr("data.opennebula_virtual_network_leases.vm", "leases.#", "0"),
+				),
+			},
+		},
+	})
+}
+
+func TestVnetLeaseOwner(t *testing.T) {
+	body := `<VNET><ID>3</ID><AR_POOL><AR><AR_ID>0</AR_ID><LEASES>
+<LEASE><IP>10.0.0.1</IP><MAC>02:00:0a:00:00:01</MAC><VM>0</VM></LEASE>
+<LEASE><IP>10.0.0.2</IP><MAC>02:00:0a:00:00:02</MAC><VNET>0</VNET></LEASE>
+<LEASE><IP>10.0.0.3</IP><MAC>02:00:0a:00:00:03</MAC><VROUTER>0</VROUTER></LEASE>
+<LEASE><IP>10.0.0.4</IP><MAC>02:00:0a:00:00:04</MAC><VM>-1</VM></LEASE>
+<LEASE><IP>10.0.0.5</IP><MAC>02:00:0a:00:00:05</MAC><VM>7</VM></LEASE>
+</LEASES></AR></AR_POOL></VNET>`
+
+	owners, err := parseVnetLeasesOwners(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]struct {
+		ownerType string
+		ownerID   int
+	}{
+		"02:00:0a:00:00:01": {"VM", 0},
+		"02:00:0a:00:00:02": {"VNET", 0},
+		"02:00:0a:00:00:03": {"VROUTER", 0},
+		"02:00:0a:00:00:04": {"HOLD", -1},
+		"02:00:0a:00:00:05": {"VM", 7},
+	}
+
+	if len(owners) != len(expected) {
+		t.Fatalf("expected %d leases, got %d", len(expected), len(owners))
+	}
+
+	for mac, e := range expected {
+		ownerType, ownerID := vnetLeaseOwner(owners[mac])
+		if ownerType != e.ownerType || ownerID != e.ownerID {
+			t.Errorf("lease %s: expected owner %s %d, got %s %d", mac, e.ownerType, e.ownerID, ownerType, ownerID)
+		}
+	}
+}
+
+var testAccDataSourceVirtualNetworkLeasesConfig = `
+resource "opennebula_virtual_network" "test" {
+	name            = "test-vnet-leases"
+	type            = "dummy"
+	bridge          = "onebr"
+	mtu             = 1500
+	gateway         = "172.16.120.1"
+	network_mask    = "255.255.255.0"
+	security_groups = [0]
+}
+
+resource "opennebula_virtual_network_address_range" "test" {
+	virtual_network_id = opennebula_virtual_network.test.id
+	ar_type            = "IP4"
+	size               = 16
+	ip4                = "172.16.120.100"
+	hold_ips           = ["172.16.120.102", "172.16.120.105"]
+}
+
+data "opennebula_virtual_network_leases" "all" {
+	virtual_network_id = opennebula_virtual_network.test.id
+
+	depends_on = [opennebula_virtual_network_address_range.test]
+}
+
+data "opennebula_virtual_network_leases" "vm" {
+	virtual_network_id = opennebula_virtual_network.test.id
+	owner_type         = "VM"
+
+	depends_on = [opennebula_virtual_network_address_range.test]
+}
+`
@@ -0,0 +1,151 @@
+package opennebula
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca/schemas/datastore"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+var datastoreFilesystemModes = []string{"shared", "ssh", "qcow2", "local"}
+var datastoreLVMModes = []string{"fs_lvm", "fs_lvm_ssh"}
+var datastoreResticCompressions = []string{"off", "auto", "max"}
+
+// datastoreDriverBlocks lists the typed driver blocks and the datastore types they apply to
+var datastoreDriverBlocks = map[string][]string{
+	"filesystem": {"IMAGE", "SYSTEM", "FILE"},
+	"lvm":        {"IMAGE", "SYSTEM"},
+	"iscsi":      {"IMAGE"},
+	"restic":     {"BACKUP"},
+	"rsync":      {"BACKUP"},
+}
+
+// datastoreDriverAttributes maps the driver blocks attributes to the datastore template keys
+var datastoreDriverAttributes = map[string]map[string]string{
+	"filesystem": {},
+	"lvm": {
+		"thin_enable": "LVM_THIN_ENABLE",
+	},
+	"iscsi": {
+		"host":  "ISCSI_HOST",
+		"user":  "ISCSI_USER",
+		"usage": "ISCSI_USAGE",
+	},
+	"restic": {
+		"password":    "RESTIC_PASSWORD",
+		"sftp_server": "RESTIC_SFTP_SERVER",
+		"sftp_user":   "RESTIC_SFTP_USER",
+		"compression": "RESTIC_COMPRESSION",
+	},
+	"rsync": {
+		"host": "RSYNC_HOST",
+		"user": "RSYNC_USER",
+	},
+}
+
+// datastoreDriverConflicts returns the driver blocks conflicting with the given block
+func datastoreDriverConflicts(block string) []string {
+	conflicts := []string{}
+	for _, other := range []string{"ceph", "custom", "filesystem", "lvm", "iscsi", "restic", "rsync"} {
+		if other != block {
+			conflicts = append(conflicts, other)
+		}
+	}
+	return conflicts
+}
+
+func validateDatastoreEnum(values []string) schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errors []error) {
+		if !contains(v.(string), values) {
+			errors = append(errors, fmt.Errorf("%q must be one of: %s", k, strings.Join(values, ",")))
+		}
+		return
+	}
+}
+
+// getDatastoreDriverBlock returns the name and the attributes of the configured driver block
+func getDatastoreDriverBlock(d *schema.ResourceData) (string, map[string]interface{}) {
+	for block := range datastoreDriverBlocks {
+		attrsList := d.Get(block).([]interface{})
+		if len(attrsList) > 0 && attrsList[0] != nil {
+			return block, attrsList[0].(map[string]interface{})
+		}
+	}
+	return "", nil
+}
+
+// addDatastoreDriverAttributes adds the drivers and the attributes of a driver block to the template
+func addDatastoreDriverAttributes(block, dsType string, attrs map[string]interface{}, tpl *datastore.Template) {
+
+	switch block {
+	case "filesystem":
+		if dsType != "SYSTEM" {
+			tpl.Add("DS_MAD", "fs")
+		}
+		tpl.Add("TM_MAD", attrs["mode"].(string))
+	case "lvm":
+		if dsType == "IMAGE" {
+			tpl.Add("DS_MAD", "fs")
+			tpl.Add("DISK_TYPE", "BLOCK")
+		}
+		tpl.Add("TM_MAD", attrs["mode"].(string))
+	case "iscsi":
+		tpl.Add("DS_MAD", "iscsi_libvirt")
+		tpl.Add("TM_MAD", "iscsi_libvirt")
+		tpl.Add("DISK_TYPE", "ISCSI")
+	case "restic", "rsync":
+		tpl.AddPair("DS_MAD", block)
+		tpl.Add("TM_MAD", "-")
+	}
+
+	updateDatastoreDriverAttributes(block, attrs, tpl)
+}
+
+// updateDatastoreDriverAttributes replaces the attributes of a driver block in the template
+func updateDatastoreDriverAttributes(block string, attrs map[string]interface{}, tpl *datastore.Template) {
+
+	for attr, key := range datastoreDriverAttributes[block] {
+		tpl.Del(key)
+
+		switch v := attrs[attr].(type) {
+		case bool:
+			tpl.AddPair(key, boolToYesNo(v))
+		case string:
+			if len(v) > 0 {
+				tpl.AddPair(key, v)
+			}
+		}
+	}
+}
+
+// flattenDatastoreDriverAttributes reads the attributes of a driver block from the template
+func flattenDatastoreDriverAttributes(block string, ds *datastore.Datastore, cfgAttrs map[string]interface{}) map[string]interface{} {
+
+	attrs := make(map[string]interface{})
+
+	switch block {
+	case "filesystem", "lvm":
+		attrs["mode"] = ds.TMMad
+	}
+
+	for attr, key := range datastoreDriverAttributes[block] {
+		value, err := ds.Template.GetStr(key)
+		if err != nil {
+			// OpenNebula may hide the secrets, keep the configured value
+			if attr == "password" {
+				attrs[attr] = cfgAttrs[attr]
+			}
+			continue
+		}
+
+		switch cfgAttrs[attr].(type) {
+		case bool:
+			attrs[attr] = yesNoToBool(value)
+		default:
+			attrs[attr] = value
+		}
+	}
+
+	return attrs
+}
@@ -0,0 +1,100 @@
+package opennebula
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var imageChecksumAlgorithms = []string{"md5", "sha256"}
+
+// imageChecksumKeys are the image template attributes storing the checksums
+var imageChecksumKeys = map[string]string{
+	"md5":    "MD5",
+	"sha256": "SHA256",
+}
+
+// parseImageChecksum splits a checksum in the <algorithm>:<value> format
+func parseImageChecksum(checksum string) (string, string, error) {
+	parts := strings.SplitN(checksum, ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("checksum %q must have the format <algorithm>:<value>", checksum)
+	}
+
+	algorithm := strings.ToLower(parts[0])
+	if !contains(algorithm, imageChecksumAlgorithms) {
+		return "", "", fmt.Errorf("checksum algorithm %q must be one of: %s", parts[0], strings.Join(imageChecksumAlgorithms, ","))
+	}
+
+	value := strings.ToLower(parts[1])
+	_, err := hex.DecodeString(value)
+	if err != nil {
+		return "", "", fmt.Errorf("checksum value %q is not hexadecimal", parts[1])
+	}
+
+	return algorithm, value, nil
+}
+
+func newChecksumHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case "md5":
+		return md5.New()
+	default:
+		return sha256.New()
+	}
+}
+
+// fileChecksum computes the checksum of a local file
+func fileChecksum(path, algorithm string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := newChecksumHash(algorithm)
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// imageSourceChecksum computes the checksum of the image source: either a local
+// file uploaded by the provider, or an HTTP(S) URL downloaded by the provider.
+// Paths local to the OpenNebula frontend can't be reached.
+func imageSourceChecksum(sourceFile, path, algorithm string) (string, error) {
+
+	if len(sourceFile) > 0 {
+		return fileChecksum(sourceFile, algorithm)
+	}
+
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		return "", fmt.Errorf("the checksum of %q can't be computed by the provider", path)
+	}
+
+	resp, err := http.Get(path)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", path, resp.Status)
+	}
+
+	h := newChecksumHash(algorithm)
+	_, err = io.Copy(h, resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %s", path, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
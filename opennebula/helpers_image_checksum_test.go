@@ -0,0 +1,52 @@
+package opennebula
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseImageChecksum(t *testing.T) {
+	algorithm, value, err := parseImageChecksum("SHA256:ABCDEF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if algorithm != "sha256" || value != "abcdef" {
+		t.Fatalf("unexpected checksum %s:%s", algorithm, value)
+	}
+
+	for _, checksum := range []string{"abcdef", "sha1:abcdef", "md5:xyz"} {
+		_, _, err := parseImageChecksum(checksum)
+		if err == nil {
+			t.Fatalf("expected an error for checksum %q", checksum)
+		}
+	}
+}
+
+func TestImageSourceChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.raw")
+	if err := os.WriteFile(path, []byte("terraform image content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	checksum, err := imageSourceChecksum(path, "", "md5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum != "1876f0d99011c1f663eeb94f0ffec35d" {
+		t.Fatalf("unexpected md5 checksum %s", checksum)
+	}
+
+	checksum, err = imageSourceChecksum(path, "", "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum != "2045cd9f99e8157dad2440aeb4283bb2e1b36046464c4aa85084eec4680fff12" {
+		t.Fatalf("unexpected sha256 checksum %s", checksum)
+	}
+
+	_, err = imageSourceChecksum("", "/var/tmp/image.raw", "md5")
+	if err == nil {
+		t.Fatal("expected an error for a frontend local path")
+	}
+}
@@ -0,0 +1,140 @@
+package opennebula
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+)
+
+// imageUploadConfig describes how local image files are made reachable by the
+// OpenNebula frontend: the provider serves them on a temporary HTTP URL
+type imageUploadConfig struct {
+	listenAddress string
+	url           string
+}
+
+// imageFileServer serves a single local file until it is stopped
+type imageFileServer struct {
+	URL    string
+	server *http.Server
+}
+
+// progressWriter logs the transfer progress of a file
+type progressWriter struct {
+	http.ResponseWriter
+	name    string
+	size    int64
+	written int64
+	lastLog time.Time
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+
+	if time.Since(w.lastLog) > 10*time.Second || w.written == w.size {
+		w.lastLog = time.Now()
+		percent := int64(100)
+		if w.size > 0 {
+			percent = w.written * 100 / w.size
+		}
+		log.Printf("[INFO] Uploading image file %s: %d%% (%d/%d bytes)", w.name, percent, w.written, w.size)
+	}
+
+	return n, err
+}
+
+// serveImageFile starts a temporary HTTP server exposing the file on an unguessable URL
+func serveImageFile(cfg *imageUploadConfig, path string) (*imageFileServer, error) {
+
+	if cfg == nil {
+		return nil, fmt.Errorf("the provider image_upload block must be defined to upload local files")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+
+	token, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	urlPath := fmt.Sprintf("/%s/%s", token, filepath.Base(path))
+
+	listener, err := net.Listen("tcp", cfg.listenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("can't listen on %s: %s", cfg.listenAddress, err)
+	}
+
+	baseURL := cfg.url
+	if len(baseURL) == 0 {
+		baseURL = "http://" + listener.Addr().String()
+	}
+
+	// serialize the downloads, the frontend may retry a failed transfer
+	var mutex sync.Mutex
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(urlPath, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		f, err := os.Open(path)
+		if err != nil {
+			log.Printf("[ERROR] Failed to open image file %s: %s", path, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		log.Printf("[INFO] Image file %s requested by %s", path, r.RemoteAddr)
+
+		pw := &progressWriter{
+			ResponseWriter: w,
+			name:           path,
+			size:           info.Size(),
+			lastLog:        time.Now(),
+		}
+		http.ServeContent(pw, r, filepath.Base(path), info.ModTime(), f)
+	})
+
+	fs := &imageFileServer{
+		URL:    strings.TrimSuffix(baseURL, "/") + urlPath,
+		server: &http.Server{Handler: mux},
+	}
+
+	go func() {
+		err := fs.server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("[ERROR] Image file server: %s", err)
+		}
+	}()
+
+	log.Printf("[INFO] Serving image file %s on %s", path, fs.URL)
+
+	return fs, nil
+}
+
+// Stop shuts the server down
+func (fs *imageFileServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := fs.server.Shutdown(ctx)
+	if err != nil {
+		log.Printf("[WARN] Failed to stop the image file server: %s", err)
+	}
+}
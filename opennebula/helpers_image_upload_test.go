@@ -0,0 +1,47 @@
+package opennebula
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeImageFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.raw")
+	content := []byte("terraform image content")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := serveImageFile(&imageUploadConfig{listenAddress: "127.0.0.1:0"}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Stop()
+
+	resp, err := http.Get(fs.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != string(content) {
+		t.Fatalf("expected %q, got %q", content, body)
+	}
+
+	// only the generated path is served
+	resp, err = http.Get(fs.URL + "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,297 @@
+package opennebula
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/schemas/securitygroup"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/schemas/shared"
+)
+
+// securityGroupSourceKey marks the rules expanded from a source security group,
+// OpenNebula keeps this attribute in the rule vector
+const securityGroupSourceKey = "SOURCE_SECURITY_GROUP_ID"
+
+// securityGroupEmptySourceIP is the address of the marker rule kept while the source
+// security group has no member IP: a rule without address would allow all of them
+const securityGroupEmptySourceIP = "0.0.0.0"
+
+// validateSecurityGroupRule checks that the rule doesn't mix the ways to define its addresses
+func validateSecurityGroupRule(rule map[string]string) error {
+
+	addressesSet := len(rule["ip"]) > 0 || len(rule["size"]) > 0
+
+	if len(rule["network_id"]) > 0 && addressesSet {
+		return fmt.Errorf("network_id can't be used with ip or size")
+	}
+
+	if len(rule["source_security_group_id"]) > 0 && (addressesSet || len(rule["network_id"]) > 0) {
+		return fmt.Errorf("source_security_group_id can't be used with network_id, ip or size")
+	}
+
+	return nil
+}
+
+// securityGroupRuleRawConfig returns the configured attributes of a rule, the
+// computed attributes kept from the state are ignored
+func securityGroupRuleRawConfig(rawRule cty.Value) map[string]string {
+	rule := make(map[string]string)
+	if rawRule.IsNull() || !rawRule.IsKnown() {
+		return rule
+	}
+
+	for attr := range securityGroupRuleKeys {
+		if !rawRule.Type().HasAttribute(attr) {
+			continue
+		}
+		value := rawRule.GetAttr(attr)
+		if value.IsNull() || !value.IsKnown() {
+			continue
+		}
+		rule[attr] = value.AsString()
+	}
+
+	return rule
+}
+
+func resourceSecurityGroupCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	err := SetTagsDiff(ctx, diff, meta)
+	if err != nil {
+		return err
+	}
+
+	rawConfig := diff.GetRawConfig()
+	if rawConfig.IsNull() || !rawConfig.IsKnown() {
+		return nil
+	}
+
+	rawRules := rawConfig.GetAttr("rule")
+	if rawRules.IsNull() || !rawRules.IsKnown() {
+		return nil
+	}
+
+	i := 0
+	for it := rawRules.ElementIterator(); it.Next(); i++ {
+		_, rawRule := it.Element()
+
+		err := validateSecurityGroupRule(securityGroupRuleRawConfig(rawRule))
+		if err != nil {
+			return fmt.Errorf("rule %d: %s", i, err)
+		}
+	}
+
+	return nil
+}
+
+func resourceSecurityGroupRuleCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+	return validateSecurityGroupRule(securityGroupRuleRawConfig(diff.GetRawConfig()))
+}
+
+// securityGroupMemberIPs returns the sorted IPv4 and IPv6 addresses of the virtual machines NICs using the security group
+func securityGroupMemberIPs(controller *goca.Controller, secGroupID int) ([]string, error) {
+
+	secGroup, err := controller.SecurityGroup(secGroupID).Info(false)
+	if err != nil {
+		return nil, err
+	}
+
+	vmIDs := make([]int, 0)
+	for _, vms := range []shared.EntitiesID{secGroup.UpdatedVMs, secGroup.OutdatedVMs, secGroup.UpdatingVMs, secGroup.ErrorVMs} {
+		vmIDs = append(vmIDs, vms.ID...)
+	}
+
+	secGroupIDStr := strconv.Itoa(secGroupID)
+	ipsMap := make(map[string]bool)
+
+	for _, vmID := range vmIDs {
+		vm, err := controller.VM(vmID).Info(false)
+		if err != nil {
+			if NoExists(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, nic := range vm.Template.GetNICs() {
+			nicSecGroups, _ := nic.Get(shared.SecurityGroups)
+			if !contains(secGroupIDStr, strings.Split(nicSecGroups, ",")) {
+				continue
+			}
+
+			for _, key := range []shared.NICKeys{shared.IP, "IP6", "IP6_GLOBAL", "IP6_ULA"} {
+				ip, _ := nic.Get(key)
+				if len(ip) > 0 {
+					ipsMap[ip] = true
+				}
+			}
+		}
+	}
+
+	ips := make([]string, 0, len(ipsMap))
+	for ip := range ipsMap {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	return ips, nil
+}
+
+// expandSecurityGroupRule returns the rule vectors attributes of a rule, a rule with
+// a source security group is expanded in one rule per IP of the group members
+func expandSecurityGroupRule(controller *goca.Controller, rule map[string]string) ([]map[string]string, error) {
+
+	sourceIDStr := rule["source_security_group_id"]
+	if len(sourceIDStr) == 0 {
+		return []map[string]string{rule}, nil
+	}
+
+	sourceID, err := strconv.Atoi(sourceIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source security group ID %q: %s", sourceIDStr, err)
+	}
+
+	ips, err := securityGroupMemberIPs(controller, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the IPs of the source security group (ID: %d): %s", sourceID, err)
+	}
+
+	// the rule is kept with an address matching no traffic until the group has members
+	if len(ips) == 0 {
+		log.Printf("[WARN] The source security group %d has no virtual machine NIC with an IP", sourceID)
+		ips = []string{securityGroupEmptySourceIP}
+	}
+
+	rules := make([]map[string]string, 0, len(ips))
+	for _, ip := range ips {
+		expanded := make(map[string]string, len(rule))
+		for k, v := range rule {
+			expanded[k] = v
+		}
+		expanded["ip"] = ip
+		expanded["size"] = "1"
+
+		rules = append(rules, expanded)
+	}
+
+	return rules, nil
+}
+
+// flattenSecurityGroupRules reads the rules of a security group, the rules expanded from a
+// source security group are merged back into a single rule. When the members IPs of the
+// source security group changed, the source is cleared to show the drift.
+func flattenSecurityGroupRules(controller *goca.Controller, rulesVectors []securitygroup.Rule) ([]map[string]interface{}, error) {
+
+	rules := make([]map[string]interface{}, 0, len(rulesVectors))
+	expandedIPs := make(map[string][]string)
+	expandedRules := make(map[string]map[string]interface{})
+
+	for _, ruleVec := range rulesVectors {
+		rule := make(map[string]interface{}, len(ruleVec.Pairs))
+		for _, pair := range ruleVec.Pairs {
+			rule[strings.ToLower(pair.Key())] = pair.Value
+		}
+
+		if _, ok := rule["source_security_group_id"]; !ok {
+			rules = append(rules, rule)
+			continue
+		}
+
+		ip, _ := rule["ip"].(string)
+		delete(rule, "ip")
+		delete(rule, "size")
+
+		hash := securityGroupRuleHash(securityGroupRuleFromVector(&ruleVec))
+		_, ok := expandedRules[hash]
+		if !ok {
+			expandedRules[hash] = rule
+			expandedIPs[hash] = []string{}
+			rules = append(rules, rule)
+		}
+		if ip != securityGroupEmptySourceIP {
+			expandedIPs[hash] = append(expandedIPs[hash], ip)
+		}
+	}
+
+	for hash, rule := range expandedRules {
+		sourceID, _ := strconv.Atoi(rule["source_security_group_id"].(string))
+
+		ips, err := securityGroupMemberIPs(controller, sourceID)
+		if err != nil && !NoExists(err) {
+			return nil, err
+		}
+
+		currentIPs := expandedIPs[hash]
+		sort.Strings(currentIPs)
+
+		if !reflect.DeepEqual(currentIPs, ips) {
+			log.Printf("[WARN] The IPs of the source security group %d changed, the rule needs to be updated", sourceID)
+			rule["source_security_group_id"] = ""
+		}
+	}
+
+	return rules, nil
+}
+
+// securityGroupCommitState summarizes the propagation of the rules to the virtual machines
+func securityGroupCommitState(secGroup *securitygroup.SecurityGroup) string {
+	if len(secGroup.OutdatedVMs.ID) > 0 || len(secGroup.UpdatingVMs.ID) > 0 {
+		return "UPDATING"
+	}
+	if len(secGroup.ErrorVMs.ID) > 0 {
+		return "ERROR"
+	}
+	return "UPDATED"
+}
+
+// waitForSecurityGroupCommit waits for the rules to be propagated to all the virtual machines,
+// it fails if some virtual machines couldn't be updated
+func waitForSecurityGroupCommit(ctx context.Context, sgc *goca.SecurityGroupController, timeout time.Duration) (*securitygroup.SecurityGroup, error) {
+
+	stateChangeConf := resource.StateChangeConf{
+		Pending:    []string{"UPDATING"},
+		Target:     []string{"UPDATED", "ERROR"},
+		Timeout:    timeout,
+		Delay:      2 * time.Second,
+		MinTimeout: 3 * time.Second,
+		Refresh: func() (interface{}, string, error) {
+
+			log.Println("Refreshing security group commit state...")
+
+			secGroup, err := sgc.Info(false)
+			if err != nil {
+				return secGroup, "", err
+			}
+
+			state := securityGroupCommitState(secGroup)
+
+			log.Printf("Security group (ID:%d) commit is %s: %d outdated, %d updating, %d error VMs",
+				secGroup.ID, state, len(secGroup.OutdatedVMs.ID), len(secGroup.UpdatingVMs.ID), len(secGroup.ErrorVMs.ID))
+
+			return secGroup, state, nil
+		},
+	}
+
+	secGroupIf, err := stateChangeConf.WaitForStateContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secGroup := secGroupIf.(*securitygroup.SecurityGroup)
+	if len(secGroup.ErrorVMs.ID) > 0 {
+		return secGroup, fmt.Errorf("rules failed to be updated on VMs %v", secGroup.ErrorVMs.ID)
+	}
+
+	return secGroup, nil
+}
@@ -0,0 +1,164 @@
+package opennebula
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/parameters"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/schemas/shared"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/schemas/template"
+)
+
+var (
+	templateVersionOfKey = "TEMPLATE_VERSION_OF"
+	templateVersionKey   = "TEMPLATE_VERSION"
+)
+
+type templateVersion struct {
+	Version int
+	ID      int
+	Name    string
+	RegTime int
+}
+
+// getTemplateVersions retrieves the versions published from a template, sorted by version number
+func getTemplateVersions(controller *goca.Controller, templateID int) ([]templateVersion, error) {
+
+	templates, err := controller.Templates().Info()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]templateVersion, 0)
+	for _, tpl := range templates.Templates {
+
+		versionOf, err := tpl.Template.GetInt(templateVersionOfKey)
+		if err != nil || versionOf != templateID {
+			continue
+		}
+
+		version, err := tpl.Template.GetInt(templateVersionKey)
+		if err != nil {
+			continue
+		}
+
+		versions = append(versions, templateVersion{
+			Version: version,
+			ID:      tpl.ID,
+			Name:    tpl.Name,
+			RegTime: tpl.RegTime,
+		})
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+
+	return versions, nil
+}
+
+// publishTemplateVersion clones the template into a new locked version, then removes
+// the oldest versions to keep only the configured number of versions
+func publishTemplateVersion(controller *goca.Controller, tpl *template.Template, nameSuffix string, keep int) (int, error) {
+
+	versions, err := getTemplateVersions(controller, tpl.ID)
+	if err != nil {
+		return -1, err
+	}
+
+	version := 1
+	if len(versions) > 0 {
+		version = versions[len(versions)-1].Version + 1
+	}
+
+	name := fmt.Sprintf("%s%s%d", tpl.Name, nameSuffix, version)
+	versionID, err := controller.Template(tpl.ID).Clone(name, false)
+	if err != nil {
+		return -1, fmt.Errorf("can't clone template %d: %s", tpl.ID, err)
+	}
+
+	vc := controller.Template(versionID)
+
+	versionTpl := fmt.Sprintf("%s = \"%d\"\n%s = \"%d\"", templateVersionOfKey, tpl.ID, templateVersionKey, version)
+	err = vc.Update(versionTpl, parameters.Merge)
+	if err != nil {
+		return -1, fmt.Errorf("can't tag template version %d: %s", versionID, err)
+	}
+
+	// A version is immutable, it can still be instantiated
+	err = vc.Lock(shared.LockManage)
+	if err != nil {
+		return -1, fmt.Errorf("can't lock template version %d: %s", versionID, err)
+	}
+
+	log.Printf("[INFO] Published version %d of template %d (ID: %d)", version, tpl.ID, versionID)
+
+	versions = append(versions, templateVersion{Version: version, ID: versionID})
+
+	if keep > 0 && len(versions) > keep {
+		for _, v := range versions[:len(versions)-keep] {
+			err := deleteTemplateVersion(controller, v.ID)
+			if err != nil {
+				return versionID, err
+			}
+		}
+	}
+
+	return versionID, nil
+}
+
+func deleteTemplateVersion(controller *goca.Controller, versionID int) error {
+
+	vc := controller.Template(versionID)
+
+	err := vc.Unlock()
+	if err != nil {
+		return fmt.Errorf("can't unlock template version %d: %s", versionID, err)
+	}
+
+	err = vc.Delete()
+	if err != nil {
+		return fmt.Errorf("can't delete template version %d: %s", versionID, err)
+	}
+
+	log.Printf("[INFO] Deleted template version (ID: %d)", versionID)
+
+	return nil
+}
+
+func flattenTemplateVersions(d *schema.ResourceData, controller *goca.Controller) error {
+
+	templateID, err := strconv.ParseInt(d.Id(), 10, 0)
+	if err != nil {
+		return err
+	}
+
+	versions, err := getTemplateVersions(controller, int(templateID))
+	if err != nil {
+		return err
+	}
+
+	versionsList := make([]interface{}, 0, len(versions))
+	latestID := -1
+	for _, v := range versions {
+		versionsList = append(versionsList, map[string]interface{}{
+			"version":     v.Version,
+			"template_id": v.ID,
+			"name":        v.Name,
+			"reg_time":    v.RegTime,
+		})
+		latestID = v.ID
+	}
+
+	err = d.Set("versions", versionsList)
+	if err != nil {
+		return err
+	}
+
+	return d.Set("latest_id", latestID)
+}
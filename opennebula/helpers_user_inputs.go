@@ -0,0 +1,458 @@
+package opennebula
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca"
+	dyn "github.com/OpenNebula/one/src/oca/go/src/goca/dynamic"
+)
+
+// User input types, as defined in the USER_INPUTS section of a VM template
+var userInputTypes = []string{
+	"text",
+	"text64",
+	"password",
+	"number",
+	"number-float",
+	"range",
+	"range-float",
+	"list",
+	"list-multiple",
+	"boolean",
+	"fixed",
+}
+
+// userInput is the decoded form of a USER_INPUTS entry: "M|type|description|params|default"
+type userInput struct {
+	Name        string
+	Mandatory   bool
+	Type        string
+	Description string
+	Params      string
+	Default     string
+}
+
+// parseUserInput decodes the OpenNebula encoding of a user input
+func parseUserInput(name, encoded string) (*userInput, error) {
+
+	fields := strings.SplitN(encoded, "|", 5)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("user input %s: %q doesn't match the format \"M|type|description|params|default\"", name, encoded)
+	}
+
+	ui := &userInput{
+		Name: strings.ToUpper(name),
+		Type: fields[1],
+	}
+
+	switch fields[0] {
+	case "M":
+		ui.Mandatory = true
+	case "O":
+		ui.Mandatory = false
+	default:
+		return nil, fmt.Errorf("user input %s: mandatory flag should be M or O, got %q", name, fields[0])
+	}
+
+	if !contains(ui.Type, userInputTypes) {
+		return nil, fmt.Errorf("user input %s: type should be one of %s, got %q", name, strings.Join(userInputTypes, ", "), ui.Type)
+	}
+
+	if len(fields) > 2 {
+		ui.Description = fields[2]
+	}
+	if len(fields) > 3 {
+		ui.Params = fields[3]
+	}
+	if len(fields) > 4 {
+		ui.Default = fields[4]
+	}
+
+	return ui, nil
+}
+
+// String encodes the user input in the format expected by OpenNebula
+func (ui *userInput) String() string {
+	mandatory := "O"
+	if ui.Mandatory {
+		mandatory = "M"
+	}
+
+	fields := []string{mandatory, ui.Type, ui.Description, ui.Params, ui.Default}
+
+	// trailing empty fields are not written
+	i := len(fields)
+	for i > 3 && fields[i-1] == "" {
+		i--
+	}
+
+	return strings.Join(fields[:i], "|")
+}
+
+// rangeBounds returns the min and max values of a range or range-float user input
+func (ui *userInput) rangeBounds() (float64, float64, error) {
+	bounds := strings.SplitN(ui.Params, "..", 2)
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("user input %s: range %q doesn't match the format \"min..max\"", ui.Name, ui.Params)
+	}
+
+	min, err := strconv.ParseFloat(bounds[0], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("user input %s: invalid range min value: %s", ui.Name, err)
+	}
+	max, err := strconv.ParseFloat(bounds[1], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("user input %s: invalid range max value: %s", ui.Name, err)
+	}
+
+	return min, max, nil
+}
+
+// options returns the list of values allowed for a list or list-multiple user input
+func (ui *userInput) options() []string {
+	if len(ui.Params) == 0 {
+		return []string{}
+	}
+	return strings.Split(ui.Params, ",")
+}
+
+// validate checks that the value is consistent with the user input definition
+func (ui *userInput) validate(value string) error {
+
+	switch ui.Type {
+	case "number":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("user input %s: %q is not an integer", ui.Name, value)
+		}
+	case "number-float":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("user input %s: %q is not a number", ui.Name, value)
+		}
+	case "range", "range-float":
+		var number float64
+		var err error
+		if ui.Type == "range" {
+			var n int
+			n, err = strconv.Atoi(value)
+			number = float64(n)
+		} else {
+			number, err = strconv.ParseFloat(value, 64)
+		}
+		if err != nil {
+			return fmt.Errorf("user input %s: %q is not a valid %s value", ui.Name, value, ui.Type)
+		}
+
+		min, max, err := ui.rangeBounds()
+		if err != nil {
+			return err
+		}
+		if number < min || number > max {
+			return fmt.Errorf("user input %s: %q is out of range %s", ui.Name, value, ui.Params)
+		}
+	case "list":
+		if !contains(value, ui.options()) {
+			return fmt.Errorf("user input %s: %q should be one of %s", ui.Name, value, ui.Params)
+		}
+	case "list-multiple":
+		for _, v := range strings.Split(value, ",") {
+			if !contains(v, ui.options()) {
+				return fmt.Errorf("user input %s: %q should be one of %s", ui.Name, v, ui.Params)
+			}
+		}
+	case "boolean":
+		if !contains(value, []string{"YES", "NO"}) {
+			return fmt.Errorf("user input %s: %q should be YES or NO", ui.Name, value)
+		}
+	case "fixed":
+		if value != ui.Default {
+			return fmt.Errorf("user input %s: value is fixed to %q", ui.Name, ui.Default)
+		}
+	}
+
+	return nil
+}
+
+// getUserInputs retrieves and decodes the USER_INPUTS section of a template
+func getUserInputs(tpl *dyn.Template) (map[string]*userInput, error) {
+
+	userInputs := make(map[string]*userInput)
+
+	uInputsVec, _ := tpl.GetVector("USER_INPUTS")
+	if uInputsVec == nil {
+		return userInputs, nil
+	}
+
+	for _, pair := range uInputsVec.Pairs {
+		ui, err := parseUserInput(pair.Key(), pair.Value)
+		if err != nil {
+			return nil, err
+		}
+		userInputs[ui.Name] = ui
+	}
+
+	return userInputs, nil
+}
+
+// validateUserInputsValues checks the values provided against the template user inputs definitions
+func validateUserInputsValues(userInputs map[string]*userInput, values map[string]interface{}) error {
+
+	for k, v := range values {
+		ui, ok := userInputs[strings.ToUpper(k)]
+		if !ok {
+			return fmt.Errorf("user input %s is not defined in the template", strings.ToUpper(k))
+		}
+
+		err := ui.validate(fmt.Sprint(v))
+		if err != nil {
+			return err
+		}
+	}
+
+	for name, ui := range userInputs {
+		if !ui.Mandatory || ui.Type == "fixed" || len(ui.Default) > 0 {
+			continue
+		}
+
+		found := false
+		for k := range values {
+			if strings.ToUpper(k) == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("user input %s is mandatory", name)
+		}
+	}
+
+	return nil
+}
+
+// addUserInputsValues adds the user inputs values to the instantiation template.
+// Mandatory inputs not provided but having a default value receive their default value.
+func addUserInputsValues(tpl *dyn.Template, userInputs map[string]*userInput, values map[string]interface{}) {
+
+	provided := make(map[string]string, len(values))
+	for k, v := range values {
+		provided[strings.ToUpper(k)] = fmt.Sprint(v)
+	}
+
+	for name, ui := range userInputs {
+		value, ok := provided[name]
+		if ok {
+			if ui.Type == "text64" {
+				value = base64.StdEncoding.EncodeToString([]byte(value))
+			}
+		} else {
+			if !ui.Mandatory || len(ui.Default) == 0 {
+				continue
+			}
+			value = ui.Default
+		}
+
+		tpl.Del(name)
+		tpl.AddPair(name, value)
+	}
+}
+
+// applyUserInputsValues validates the values against the user inputs of the template
+// content then adds them to the instantiation template
+func applyUserInputsValues(tpl *dyn.Template, templateContent *dyn.Template, values map[string]interface{}) error {
+
+	userInputs, err := getUserInputs(templateContent)
+	if err != nil {
+		return err
+	}
+
+	err = validateUserInputsValues(userInputs, values)
+	if err != nil {
+		return err
+	}
+
+	addUserInputsValues(tpl, userInputs, values)
+
+	return nil
+}
+
+// customizeDiffUserInputsValues validates at plan time the user inputs values against
+// the user inputs definitions of the template to instantiate
+func customizeDiffUserInputsValues(diff *schema.ResourceDiff, controller *goca.Controller, templateID int) error {
+
+	if diff.Id() != "" && !diff.HasChange("user_inputs_values") {
+		return nil
+	}
+
+	values := diff.Get("user_inputs_values").(map[string]interface{})
+	if len(values) == 0 {
+		return nil
+	}
+
+	tpl, err := controller.Template(templateID).Info(false, false)
+	if err != nil {
+		return fmt.Errorf("template (ID: %d): can't retrieve user inputs: %s", templateID, err)
+	}
+
+	userInputs, err := getUserInputs(&tpl.Template.Template)
+	if err != nil {
+		return fmt.Errorf("template (ID: %d): %s", templateID, err)
+	}
+
+	err = validateUserInputsValues(userInputs, values)
+	if err != nil {
+		return fmt.Errorf("template (ID: %d): %s", templateID, err)
+	}
+
+	return nil
+}
+
+// expandUserInput builds a user input from a user_input block and checks its consistency
+func expandUserInput(config map[string]interface{}) (*userInput, error) {
+
+	ui := &userInput{
+		Name:        strings.ToUpper(config["name"].(string)),
+		Type:        config["type"].(string),
+		Mandatory:   config["mandatory"].(bool),
+		Description: config["description"].(string),
+		Default:     config["default"].(string),
+	}
+
+	if strings.Contains(ui.Description, "|") {
+		return nil, fmt.Errorf("user input %s: description can't contain the \"|\" character", ui.Name)
+	}
+
+	options := make([]string, 0)
+	for _, o := range config["options"].([]interface{}) {
+		options = append(options, o.(string))
+	}
+
+	switch ui.Type {
+	case "range", "range-float":
+		min := config["range_min"].(float64)
+		max := config["range_max"].(float64)
+		if min >= max {
+			return nil, fmt.Errorf("user input %s: range_min should be lower than range_max", ui.Name)
+		}
+		if ui.Type == "range" && (min != float64(int(min)) || max != float64(int(max))) {
+			return nil, fmt.Errorf("user input %s: range bounds should be integers", ui.Name)
+		}
+		ui.Params = fmt.Sprintf("%s..%s", strconv.FormatFloat(min, 'f', -1, 64), strconv.FormatFloat(max, 'f', -1, 64))
+	case "list", "list-multiple":
+		if len(options) == 0 {
+			return nil, fmt.Errorf("user input %s: options are required for the %s type", ui.Name, ui.Type)
+		}
+		ui.Params = strings.Join(options, ",")
+	}
+
+	if len(options) > 0 && ui.Type != "list" && ui.Type != "list-multiple" {
+		return nil, fmt.Errorf("user input %s: options can only be used with list types", ui.Name)
+	}
+
+	if len(ui.Default) > 0 && ui.Type != "fixed" {
+		err := ui.validate(ui.Default)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default value: %s", err)
+		}
+	}
+
+	return ui, nil
+}
+
+// flattenUserInput returns the user_input block of a user input
+func flattenUserInput(ui *userInput) (map[string]interface{}, error) {
+
+	config := map[string]interface{}{
+		"name":        ui.Name,
+		"type":        ui.Type,
+		"mandatory":   ui.Mandatory,
+		"description": ui.Description,
+		"default":     ui.Default,
+		"options":     []interface{}{},
+		"range_min":   0.0,
+		"range_max":   0.0,
+	}
+
+	switch ui.Type {
+	case "range", "range-float":
+		min, max, err := ui.rangeBounds()
+		if err != nil {
+			return nil, err
+		}
+		config["range_min"] = min
+		config["range_max"] = max
+	case "list", "list-multiple":
+		options := make([]interface{}, 0)
+		for _, o := range ui.options() {
+			options = append(options, o)
+		}
+		config["options"] = options
+	}
+
+	return config, nil
+}
+
+// addUserInputs adds the USER_INPUTS section to the template, from user_inputs or user_input
+func addUserInputs(d *schema.ResourceData, tpl *dyn.Template) error {
+
+	uInputs := d.Get("user_inputs").(map[string]interface{})
+	uInputList := d.Get("user_input").(*schema.Set).List()
+
+	if len(uInputs) == 0 && len(uInputList) == 0 {
+		return nil
+	}
+
+	vec := tpl.AddVector("USER_INPUTS")
+
+	for k, v := range uInputs {
+		vec.AddPair(k, v)
+	}
+
+	for _, uInputIf := range uInputList {
+		ui, err := expandUserInput(uInputIf.(map[string]interface{}))
+		if err != nil {
+			return err
+		}
+		vec.AddPair(ui.Name, ui.String())
+	}
+
+	return nil
+}
+
+// flattenUserInputs reads the USER_INPUTS section of the template into user_input when
+// the structured form is used, into user_inputs otherwise
+func flattenUserInputs(d *schema.ResourceData, tpl *dyn.Template) error {
+
+	if d.Get("user_input").(*schema.Set).Len() == 0 {
+		uInputs, _ := tpl.GetVector("USER_INPUTS")
+		if uInputs == nil || len(uInputs.Pairs) == 0 {
+			return nil
+		}
+
+		uInputsMap := make(map[string]interface{}, 0)
+		for _, ui := range uInputs.Pairs {
+			uInputsMap[ui.Key()] = ui.Value
+		}
+
+		return d.Set("user_inputs", uInputsMap)
+	}
+
+	userInputs, err := getUserInputs(tpl)
+	if err != nil {
+		return err
+	}
+
+	uInputList := make([]interface{}, 0, len(userInputs))
+	for _, ui := range userInputs {
+		config, err := flattenUserInput(ui)
+		if err != nil {
+			return err
+		}
+		uInputList = append(uInputList, config)
+	}
+
+	return d.Set("user_input", uInputList)
+}
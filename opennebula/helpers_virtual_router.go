@@ -0,0 +1,296 @@
+package opennebula
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca"
+	dyn "github.com/OpenNebula/one/src/oca/go/src/goca/dynamic"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/schemas/vm"
+	vmk "github.com/OpenNebula/one/src/oca/go/src/goca/schemas/vm/keys"
+)
+
+// vrouterKeepalivedKeys maps the keepalived attributes to the virtual router template keys
+var vrouterKeepalivedKeys = map[string]string{
+	"id":       "KEEPALIVED_ID",
+	"password": "KEEPALIVED_PASSWORD",
+}
+
+// vrouterServicesKeys maps the services attributes to the context keys of the
+// virtual router appliance, they are handled since OpenNebula 6.10
+var vrouterServicesKeys = map[string]string{
+	"forwarding":         "ONEAPP_VNF_ROUTER4_ENABLED",
+	"nat":                "ONEAPP_VNF_NAT4_ENABLED",
+	"nat_interfaces_out": "ONEAPP_VNF_NAT4_INTERFACES_OUT",
+	"dns":                "ONEAPP_VNF_DNS_ENABLED",
+	"dns_interfaces":     "ONEAPP_VNF_DNS_INTERFACES",
+	"dhcp":               "ONEAPP_VNF_DHCP4_ENABLED",
+	"dhcp_interfaces":    "ONEAPP_VNF_DHCP4_INTERFACES",
+}
+
+func vrouterKeepalivedSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Description: "Keepalived settings of the virtual router instances",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"id": {
+					Type:        schema.TypeInt,
+					Optional:    true,
+					Description: "Virtual router ID of the VRRP instance, between 1 and 255",
+					ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+						value := v.(int)
+						if value < 1 || value > 255 {
+							errors = append(errors, fmt.Errorf("%q must be between 1 and 255", k))
+						}
+						return
+					},
+				},
+				"password": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Sensitive:   true,
+					Description: "Password used to authenticate the VRRP peers, up to 8 characters",
+					ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+						if len(v.(string)) > 8 {
+							errors = append(errors, fmt.Errorf("%q must be up to 8 characters", k))
+						}
+						return
+					},
+				},
+			},
+		},
+	}
+}
+
+func vrouterServicesSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Description: "Services of the virtual router appliance (OpenNebula 6.10+)",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"forwarding": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Description: "Enable the IPv4 forwarding between the interfaces",
+				},
+				"nat": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Description: "Enable the IPv4 masquerading",
+				},
+				"nat_interfaces_out": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "Space separated list of the interfaces to masquerade the traffic through",
+				},
+				"dns": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Description: "Enable the DNS recursor",
+				},
+				"dns_interfaces": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "Space separated list of the interfaces to listen on for DNS requests",
+				},
+				"dhcp": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Description: "Enable the DHCPv4 server",
+				},
+				"dhcp_interfaces": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "Space separated list of the interfaces to serve DHCP requests on",
+				},
+			},
+		},
+	}
+}
+
+// vrouterBlockPairs returns the template pairs of a keepalived or services block
+func vrouterBlockPairs(blocks []interface{}, keys map[string]string) map[string]string {
+	pairs := make(map[string]string)
+	if len(blocks) == 0 || blocks[0] == nil {
+		return pairs
+	}
+
+	for attr, value := range blocks[0].(map[string]interface{}) {
+		key, ok := keys[attr]
+		if !ok {
+			continue
+		}
+
+		switch v := value.(type) {
+		case bool:
+			pairs[key] = boolToYesNo(v)
+		case int:
+			if v != 0 {
+				pairs[key] = fmt.Sprint(v)
+			}
+		case string:
+			if len(v) > 0 {
+				pairs[key] = v
+			}
+		}
+	}
+
+	return pairs
+}
+
+// generateVirtualRouterHA returns the virtual router template pairs of the keepalived and services blocks
+func generateVirtualRouterHA(keepalived, services []interface{}) map[string]string {
+	pairs := vrouterBlockPairs(keepalived, vrouterKeepalivedKeys)
+	for k, v := range vrouterBlockPairs(services, vrouterServicesKeys) {
+		pairs[k] = v
+	}
+	return pairs
+}
+
+// vrouterInstanceContext returns the context of the instances from the virtual router
+// template pairs, OpenNebula prefixes the keepalived ones with VROUTER_
+func vrouterInstanceContext(pairs map[string]string) map[string]string {
+	context := make(map[string]string, len(pairs))
+	for k, v := range pairs {
+		if strings.HasPrefix(k, "KEEPALIVED_") {
+			k = "VROUTER_" + k
+		}
+		context[k] = v
+	}
+	return context
+}
+
+// flattenVirtualRouterHA reads the keepalived and services blocks from the virtual router template
+func flattenVirtualRouterHA(d *schema.ResourceData, tpl *dyn.Template) error {
+
+	keepalived := make(map[string]interface{})
+	keepalivedSet := false
+
+	id, err := tpl.GetInt(vrouterKeepalivedKeys["id"])
+	if err == nil {
+		keepalived["id"] = id
+		keepalivedSet = true
+	}
+	password, err := tpl.GetStr(vrouterKeepalivedKeys["password"])
+	if err == nil {
+		keepalived["password"] = password
+		keepalivedSet = true
+	}
+
+	if keepalivedSet {
+		err = d.Set("keepalived", []interface{}{keepalived})
+	} else {
+		err = d.Set("keepalived", nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	services := make(map[string]interface{})
+	servicesSet := false
+
+	for attr, key := range vrouterServicesKeys {
+		value, err := tpl.GetStr(key)
+		if err != nil {
+			continue
+		}
+		servicesSet = true
+
+		if strings.HasSuffix(key, "_ENABLED") {
+			services[attr] = strings.ToUpper(value) == "YES"
+		} else {
+			services[attr] = value
+		}
+	}
+
+	if servicesSet {
+		err = d.Set("services", []interface{}{services})
+	} else {
+		err = d.Set("services", nil)
+	}
+
+	return err
+}
+
+// updateVRouterInstancesContext pushes the keepalived and services settings
+// in the context of the running virtual router instances
+func updateVRouterInstancesContext(controller *goca.Controller, vmIDs []int, oldPairs, newPairs map[string]string) error {
+
+	oldContext := vrouterInstanceContext(oldPairs)
+	newContext := vrouterInstanceContext(newPairs)
+
+	for _, vmID := range vmIDs {
+		vmc := controller.VM(vmID)
+
+		vmInfos, err := vmc.Info(false)
+		if err != nil {
+			if NoExists(err) {
+				continue
+			}
+			return fmt.Errorf("virtual router instance (ID: %d): %s", vmID, err)
+		}
+
+		// retrieve only template sections managed by updateconf method
+		tpl := vm.NewTemplate()
+		for _, name := range []string{"OS", "FEATURES", "INPUT", "GRAPHICS", "RAW", "CONTEXT", "CPU_MODEL"} {
+			vectors := vmInfos.Template.GetVectors(name)
+			for _, vec := range vectors {
+				tpl.Elements = append(tpl.Elements, vec)
+			}
+		}
+
+		contextVec, err := tpl.GetVector(vmk.ContextVec)
+		if err != nil {
+			contextVec = tpl.AddVector(vmk.ContextVec)
+		}
+
+		for key := range oldContext {
+			contextVec.Del(key)
+		}
+		for key, value := range newContext {
+			contextVec.Del(key)
+			contextVec.AddPair(key, value)
+		}
+
+		// the context isn't logged as it contains the keepalived password
+		log.Printf("[INFO] Update virtual router instance %d context", vmID)
+
+		err = vmc.UpdateConf(tpl.String())
+		if err != nil {
+			return fmt.Errorf("virtual router instance (ID: %d): %s", vmID, err)
+		}
+	}
+
+	return nil
+}
+
+// addVRouterServicesContext adds the services settings of the virtual router to the context of a new instance,
+// OpenNebula already adds the keepalived ones
+func addVRouterServicesContext(tpl *vm.Template, vrTpl *dyn.Template) {
+
+	contextVec, err := tpl.GetVector(vmk.ContextVec)
+	if err != nil {
+		contextVec = tpl.AddVector(vmk.ContextVec)
+	}
+
+	for _, key := range vrouterServicesKeys {
+		value, err := vrTpl.GetStr(key)
+		if err != nil {
+			continue
+		}
+		contextVec.Del(key)
+		contextVec.AddPair(key, value)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,6 +19,31 @@ import (
 
 var vNetARAddInstancesStates = []string{vn.Ready.String()}
 
+// vNetIPAMAllocatedKeys are the AR attributes that an IPAM driver may set or rewrite
+var vNetIPAMAllocatedKeys = []string{"IP", "IP6", "MAC", "GLOBAL_PREFIX", "ULA_PREFIX", "SIZE"}
+
+// vNetIPAMError adds some context to an error returned by OpenNebula when an
+// IPAM driver is involved in an address range operation
+func vNetIPAMError(ipam, operation string, err error) error {
+	if len(ipam) == 0 {
+		return err
+	}
+
+	return fmt.Errorf("IPAM driver %q failed to %s the address range: %s. "+
+		"Check that the driver is enabled in the IPAM_MAD section of oned.conf, "+
+		"and look at the driver logs in /var/log/one/oned.log on the frontend", ipam, operation, err)
+}
+
+// getARIPAM returns the IPAM driver of an address range, empty if none
+func getARIPAM(AR *vn.AR) string {
+	for _, pair := range AR.Custom {
+		if pair.Key() == "IPAM_MAD" {
+			return pair.Value
+		}
+	}
+	return ""
+}
+
 // convert a static address range (vn.AR) struct to a vector (vn.AddressRange)
 func getARTemplate(AR *vn.AR) *vn.AddressRange {
 
@@ -63,9 +89,11 @@ func vNetARAdd(ctx context.Context, timeout time.Duration, vnc *goca.VirtualNetw
 		return -1, err
 	}
 
+	ipam, _ := arTpl.GetStr("IPAM_MAD")
+
 	err = vnc.AddAR(arTpl.String())
 	if err != nil {
-		return -1, err
+		return -1, vNetIPAMError(ipam, "allocate", err)
 	}
 
 	var attachedAR *vn.AddressRange
@@ -100,6 +128,11 @@ func vNetARAdd(ctx context.Context, timeout time.Duration, vnc *goca.VirtualNetw
 
 				for _, pair := range arTpl.Pairs {
 
+					// the IPAM driver may have allocated different values
+					if len(ipam) > 0 && contains(pair.Key(), vNetIPAMAllocatedKeys) {
+						continue
+					}
+
 					value, err := ar.GetStr(pair.Key())
 					if err != nil {
 						continue updatedARsLoop
@@ -160,6 +193,15 @@ func vNetARRemove(ctx context.Context, oneVersion *version.Version, timeout time
 		return err
 	}
 
+	ipam := ""
+	arIDStr := fmt.Sprint(arID)
+	for i, AR := range vNetInfos.ARs {
+		if AR.ID == arIDStr {
+			ipam = getARIPAM(&vNetInfos.ARs[i])
+			break
+		}
+	}
+
 	// virtual network states were introduce with OpenNebula 6.4 release
 	requiredVersion, _ := version.NewVersion("6.4.0")
 
@@ -180,7 +222,7 @@ func vNetARRemove(ctx context.Context, oneVersion *version.Version, timeout time
 			if strings.Contains(err.Error(), "Address Range has leases in use") {
 				return resource.RetryableError(err)
 			}
-			return resource.NonRetryableError(err)
+			return resource.NonRetryableError(vNetIPAMError(ipam, "free", err))
 		}
 		attached, err := isVRARAttached(vnc, arID)
 		if err != nil {
@@ -200,3 +242,387 @@ func vNetARRemove(ctx context.Context, oneVersion *version.Version, timeout time
 
 	return nil
 }
+
+var vnetVXLANModes = []string{"evpn", "multicast"}
+var vnetVXLANTEPs = []string{"dev", "local_ip"}
+var vnetQinQTypes = []string{"802.1Q", "802.1ad"}
+
+// vnetDriverBlocks lists the typed driver blocks and the virtual network types they apply to
+var vnetDriverBlocks = map[string][]string{
+	"vxlan": {"vxlan"},
+	"qinq":  {"ovswitch"},
+}
+
+// vnetBridgeTypes lists the bridge types and the virtual network types they apply to
+var vnetBridgeTypes = map[string][]string{
+	"linux":            {"dummy", "bridge", "fw", "ebtables", "802.1Q", "vxlan"},
+	"openvswitch":      {"ovswitch", "ovswitch_vxlan"},
+	"openvswitch_dpdk": {"ovswitch", "ovswitch_vxlan"},
+}
+
+// vnetDriverAttributes maps the driver and QoS blocks attributes to the virtual network template keys
+var vnetDriverAttributes = map[string]map[string]string{
+	"vxlan": {
+		"mode":              "VXLAN_MODE",
+		"tep":               "VXLAN_TEP",
+		"multicast_address": "VXLAN_MC",
+	},
+	"qinq": {
+		"type":   "QINQ_TYPE",
+		"cvlans": "CVLANS",
+	},
+	"qos": {
+		"inbound_avg_bw":   "INBOUND_AVG_BW",
+		"inbound_peak_bw":  "INBOUND_PEAK_BW",
+		"inbound_peak_kb":  "INBOUND_PEAK_KB",
+		"outbound_avg_bw":  "OUTBOUND_AVG_BW",
+		"outbound_peak_bw": "OUTBOUND_PEAK_BW",
+		"outbound_peak_kb": "OUTBOUND_PEAK_KB",
+	},
+}
+
+func qosSchema(description string) *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeInt,
+		Optional:    true,
+		Description: description,
+		ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+			if v.(int) < 0 {
+				errors = append(errors, fmt.Errorf("%q must be a positive integer", k))
+			}
+			return
+		},
+	}
+}
+
+// vnetDriverSchemas returns the driver specific attributes shared by the virtual network
+// and the virtual network template resources
+func vnetDriverSchemas(conflicts []string) map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"outer_vlan_id": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			Description:   "Outer VLAN ID (VXLAN VNI). Only if 'type' is ovswitch_vxlan",
+			ConflictsWith: conflicts,
+		},
+		"bridge_type": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			Computed:      true,
+			Description:   "Bridge type: linux, openvswitch or openvswitch_dpdk. Defaults to the type driver bridge",
+			ConflictsWith: conflicts,
+			ValidateFunc:  validateVnetEnum([]string{"linux", "openvswitch", "openvswitch_dpdk"}),
+		},
+		"vxlan": {
+			Type:          schema.TypeList,
+			Optional:      true,
+			MaxItems:      1,
+			Description:   "VXLAN driver attributes. Only if 'type' is vxlan",
+			ConflictsWith: conflicts,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"mode": {
+						Type:         schema.TypeString,
+						Optional:     true,
+						Description:  "Multicast protocol for multi destination BUM traffic: evpn or multicast",
+						ValidateFunc: validateVnetEnum(vnetVXLANModes),
+					},
+					"tep": {
+						Type:         schema.TypeString,
+						Optional:     true,
+						Description:  "Tunnel endpoint communication type, only with evpn mode: dev or local_ip",
+						ValidateFunc: validateVnetEnum(vnetVXLANTEPs),
+					},
+					"multicast_address": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "Base multicast address for each VLAN, only with multicast mode",
+					},
+				},
+			},
+		},
+		"qinq": {
+			Type:          schema.TypeList,
+			Optional:      true,
+			MaxItems:      1,
+			Description:   "Open vSwitch QinQ attributes. Only if 'type' is ovswitch",
+			ConflictsWith: conflicts,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"type": {
+						Type:         schema.TypeString,
+						Optional:     true,
+						Description:  "Ethertype of the outer tag: 802.1Q or 802.1ad",
+						ValidateFunc: validateVnetEnum(vnetQinQTypes),
+					},
+					"cvlans": {
+						Type:        schema.TypeString,
+						Required:    true,
+						Description: "Customer VLAN IDs, as a comma separated list of IDs or ranges",
+					},
+				},
+			},
+		},
+		"qos": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			MaxItems:    1,
+			Description: "Inbound and outbound QoS of the virtual machine NICs",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"inbound_avg_bw":   qosSchema("Average bitrate for the interface in kilobytes/second for inbound traffic"),
+					"inbound_peak_bw":  qosSchema("Maximum bitrate for the interface in kilobytes/second for inbound traffic"),
+					"inbound_peak_kb":  qosSchema("Data that can be transmitted at peak speed in kilobytes for inbound traffic"),
+					"outbound_avg_bw":  qosSchema("Average bitrate for the interface in kilobytes/second for outbound traffic"),
+					"outbound_peak_bw": qosSchema("Maximum bitrate for the interface in kilobytes/second for outbound traffic"),
+					"outbound_peak_kb": qosSchema("Data that can be transmitted at peak speed in kilobytes for outbound traffic"),
+				},
+			},
+		},
+	}
+}
+
+func validateVnetEnum(values []string) schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errors []error) {
+		if !contains(v.(string), values) {
+			errors = append(errors, fmt.Errorf("%q must be one of: %s", k, strings.Join(values, ",")))
+		}
+		return
+	}
+}
+
+// validateVnetDriverAttributes checks that the configured driver attributes apply to the virtual network type
+func validateVnetDriverAttributes(vnmad string, blocks []string, bridgeType, outerVlanID string) error {
+
+	for _, block := range blocks {
+		vnmads, ok := vnetDriverBlocks[block]
+		if ok && !contains(vnmad, vnmads) {
+			return fmt.Errorf("%s block can't be used with a virtual network of type %s, allowed types: %s", block, vnmad, strings.Join(vnmads, ","))
+		}
+	}
+
+	if len(bridgeType) > 0 && !contains(vnmad, vnetBridgeTypes[bridgeType]) {
+		return fmt.Errorf("bridge_type %s can't be used with a virtual network of type %s, allowed types: %s", bridgeType, vnmad, strings.Join(vnetBridgeTypes[bridgeType], ","))
+	}
+
+	if vnmad == "ovswitch_vxlan" && len(outerVlanID) == 0 {
+		return fmt.Errorf("a virtual network of type ovswitch_vxlan requires an outer_vlan_id")
+	}
+	if vnmad != "ovswitch_vxlan" && len(outerVlanID) > 0 {
+		return fmt.Errorf("outer_vlan_id can't be used with a virtual network of type %s, allowed types: ovswitch_vxlan", vnmad)
+	}
+
+	return nil
+}
+
+// validateVnetVXLANAttributes checks that the VXLAN attributes apply to the VXLAN mode,
+// the mode defaults to multicast when it's unset
+func validateVnetVXLANAttributes(mode, tep, multicastAddress string) error {
+
+	if len(tep) > 0 && mode != "evpn" {
+		return fmt.Errorf("vxlan tep can only be used with the evpn mode")
+	}
+	if len(multicastAddress) > 0 && mode == "evpn" {
+		return fmt.Errorf("vxlan multicast_address can only be used with the multicast mode")
+	}
+
+	return nil
+}
+
+// vnetDriverCustomizeDiff validates the driver attributes against the virtual network type
+func vnetDriverCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	err := SetTagsDiff(ctx, diff, meta)
+	if err != nil {
+		return err
+	}
+
+	vnmad := diff.Get("type").(string)
+
+	blocks := []string{}
+	for block := range vnetDriverBlocks {
+		if len(diff.Get(block).([]interface{})) > 0 {
+			blocks = append(blocks, block)
+		}
+	}
+
+	// bridge_type is computed, only the configured value is checked
+	bridgeType := ""
+	rawConfig := diff.GetRawConfig()
+	if !rawConfig.IsNull() && rawConfig.IsKnown() {
+		bridgeTypeCfg := rawConfig.GetAttr("bridge_type")
+		if !bridgeTypeCfg.IsNull() && bridgeTypeCfg.IsKnown() {
+			bridgeType = bridgeTypeCfg.AsString()
+		}
+	}
+	if len(bridgeType) == 0 && diff.HasChange("type") {
+		err = diff.SetNewComputed("bridge_type")
+		if err != nil {
+			return err
+		}
+	}
+
+	vxlan := diff.Get("vxlan").([]interface{})
+	if len(vxlan) > 0 && vxlan[0] != nil {
+		attrs := vxlan[0].(map[string]interface{})
+		err = validateVnetVXLANAttributes(attrs["mode"].(string), attrs["tep"].(string), attrs["multicast_address"].(string))
+		if err != nil {
+			return err
+		}
+	}
+
+	// the type inherited from a virtual network template is only known after the creation
+	if !diff.NewValueKnown("type") {
+		return nil
+	}
+
+	return validateVnetDriverAttributes(vnmad, blocks, bridgeType, diff.Get("outer_vlan_id").(string))
+}
+
+// getVnetDriverBlock returns the attributes of a configured driver or QoS block
+func getVnetDriverBlock(d *schema.ResourceData, block string) map[string]interface{} {
+	attrsList := d.Get(block).([]interface{})
+	if len(attrsList) == 0 || attrsList[0] == nil {
+		return nil
+	}
+	return attrsList[0].(map[string]interface{})
+}
+
+// updateVnetDriverAttributes replaces the attributes of a driver or QoS block in the template
+func updateVnetDriverAttributes(block string, attrs map[string]interface{}, tpl *vn.Template) {
+
+	for attr, key := range vnetDriverAttributes[block] {
+		tpl.Del(key)
+
+		switch v := attrs[attr].(type) {
+		case int:
+			if v > 0 {
+				tpl.AddPair(key, v)
+			}
+		case string:
+			if len(v) > 0 {
+				tpl.AddPair(key, v)
+			}
+		}
+	}
+}
+
+// flattenVnetDriverAttributes reads the attributes of a driver or QoS block from the template,
+// it returns an empty list when none of them is set
+func flattenVnetDriverAttributes(block string, tpl *vn.Template) ([]interface{}, error) {
+
+	attrs := make(map[string]interface{})
+
+	for attr, key := range vnetDriverAttributes[block] {
+		value, err := tpl.GetStr(key)
+		if err != nil || len(value) == 0 {
+			continue
+		}
+
+		if block == "qos" {
+			valueInt, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert %s as integer: %s", key, err)
+			}
+			attrs[attr] = valueInt
+			continue
+		}
+		attrs[attr] = value
+	}
+
+	if len(attrs) == 0 {
+		return []interface{}{}, nil
+	}
+
+	return []interface{}{attrs}, nil
+}
+
+// flattenVnetDriverSchemas reads the driver specific attributes from the template
+func flattenVnetDriverSchemas(d *schema.ResourceData, tpl *vn.Template) error {
+
+	outerVlanID, _ := tpl.GetStr("OUTER_VLAN_ID")
+	d.Set("outer_vlan_id", outerVlanID)
+
+	bridgeType, _ := tpl.GetStr("BRIDGE_TYPE")
+	if len(bridgeType) > 0 {
+		d.Set("bridge_type", bridgeType)
+	}
+
+	for _, block := range []string{"vxlan", "qinq", "qos"} {
+		attrs, err := flattenVnetDriverAttributes(block, tpl)
+		if err != nil {
+			return err
+		}
+
+		err = d.Set(block, attrs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// vnetUpdateState summarizes the propagation of a driver update to the virtual machines
+func vnetUpdateState(vNet *vn.VirtualNetwork) (string, error) {
+	state, err := vNet.State()
+	if err != nil {
+		return "", err
+	}
+
+	switch state {
+	case vn.Ready:
+		if len(vNet.OutdatedVMs.ID) > 0 || len(vNet.UpdatingVMs.ID) > 0 {
+			return "UPDATING", nil
+		}
+		return "UPDATED", nil
+	case vn.UpdateFailure, vn.Error:
+		return state.String(), fmt.Errorf("virtual network (ID:%d) entered %s state", vNet.ID, state.String())
+	}
+
+	return state.String(), nil
+}
+
+// waitForVNetworkUpdate waits for the driver update to be propagated to all the virtual machines,
+// it fails if the update failed or if some virtual machines couldn't be updated
+func waitForVNetworkUpdate(ctx context.Context, vnc *goca.VirtualNetworkController, timeout time.Duration) (*vn.VirtualNetwork, error) {
+
+	stateChangeConf := resource.StateChangeConf{
+		Pending:    []string{"UPDATING", vn.LockCreate.String()},
+		Target:     []string{"UPDATED"},
+		Timeout:    timeout,
+		Delay:      2 * time.Second,
+		MinTimeout: 3 * time.Second,
+		Refresh: func() (interface{}, string, error) {
+
+			log.Println("Refreshing virtual network update state...")
+
+			vNet, err := vnc.Info(false)
+			if err != nil {
+				return vNet, "", err
+			}
+
+			state, err := vnetUpdateState(vNet)
+			if err != nil {
+				return vNet, state, err
+			}
+
+			log.Printf("Virtual network (ID:%d) update is %s: %d outdated, %d updating, %d error VMs",
+				vNet.ID, state, len(vNet.OutdatedVMs.ID), len(vNet.UpdatingVMs.ID), len(vNet.ErrorVMs.ID))
+
+			return vNet, state, nil
+		},
+	}
+
+	vNetIf, err := stateChangeConf.WaitForStateContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	vNet := vNetIf.(*vn.VirtualNetwork)
+	if len(vNet.ErrorVMs.ID) > 0 {
+		return vNet, fmt.Errorf("driver update failed on VMs %v", vNet.ErrorVMs.ID)
+	}
+
+	return vNet, nil
+}
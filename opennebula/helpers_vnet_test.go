@@ -0,0 +1,89 @@
+package opennebula
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVNetIPAMError(t *testing.T) {
+	err := fmt.Errorf("[one.vn.add_ar] Cannot allocate address range")
+
+	if vNetIPAMError("", "allocate", err) != err {
+		t.Fatalf("expected the error to be unchanged without IPAM driver")
+	}
+
+	ipamErr := vNetIPAMError("aws", "allocate", err)
+	for _, expected := range []string{`IPAM driver "aws" failed to allocate`, err.Error(), "oned.conf", "oned.log"} {
+		if !strings.Contains(ipamErr.Error(), expected) {
+			t.Fatalf("expected %q in %q", expected, ipamErr)
+		}
+	}
+}
+
+func TestValidateVnetDriverAttributes(t *testing.T) {
+	valid := []struct {
+		vnmad       string
+		blocks      []string
+		bridgeType  string
+		outerVlanID string
+	}{
+		{"vxlan", []string{"vxlan"}, "linux", ""},
+		{"ovswitch", []string{"qinq"}, "openvswitch_dpdk", ""},
+		{"ovswitch_vxlan", nil, "openvswitch", "100"},
+		{"bridge", nil, "", ""},
+	}
+	for _, c := range valid {
+		err := validateVnetDriverAttributes(c.vnmad, c.blocks, c.bridgeType, c.outerVlanID)
+		if err != nil {
+			t.Fatalf("unexpected error for type %s: %s", c.vnmad, err)
+		}
+	}
+
+	invalid := []struct {
+		vnmad       string
+		blocks      []string
+		bridgeType  string
+		outerVlanID string
+	}{
+		{"bridge", []string{"vxlan"}, "", ""},
+		{"802.1Q", []string{"qinq"}, "", ""},
+		{"ovswitch", nil, "linux", ""},
+		{"vxlan", nil, "openvswitch", ""},
+		{"ovswitch_vxlan", nil, "", ""},
+		{"802.1Q", nil, "", "100"},
+	}
+	for _, c := range invalid {
+		err := validateVnetDriverAttributes(c.vnmad, c.blocks, c.bridgeType, c.outerVlanID)
+		if err == nil {
+			t.Fatalf("expected an error for type %s with %v, bridge type %q and outer VLAN ID %q", c.vnmad, c.blocks, c.bridgeType, c.outerVlanID)
+		}
+	}
+}
+
+func TestValidateVnetVXLANAttributes(t *testing.T) {
+	valid := [][3]string{
+		{"evpn", "local_ip", ""},
+		{"multicast", "", "239.0.0.0"},
+		{"", "", "239.0.0.0"},
+		{"", "", ""},
+	}
+	for _, c := range valid {
+		err := validateVnetVXLANAttributes(c[0], c[1], c[2])
+		if err != nil {
+			t.Fatalf("unexpected error for mode %q: %s", c[0], err)
+		}
+	}
+
+	invalid := [][3]string{
+		{"multicast", "dev", ""},
+		{"", "local_ip", ""},
+		{"evpn", "", "239.0.0.0"},
+	}
+	for _, c := range invalid {
+		err := validateVnetVXLANAttributes(c[0], c[1], c[2])
+		if err == nil {
+			t.Fatalf("expected an error for mode %q with tep %q and multicast address %q", c[0], c[1], c[2])
+		}
+	}
+}
@@ -63,6 +63,26 @@ func Provider() *schema.Provider {
 					},
 				},
 			},
+			"image_upload": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Serve local image files to the OpenNebula frontend on a temporary HTTP URL",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"listen_address": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Address on which the provider listens to serve the files, i.e. 0.0.0.0:8080",
+						},
+						"url": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Base URL used by the frontend to reach the provider. Defaults to http://<listen_address>",
+						},
+					},
+				},
+			},
 		},
 
 		DataSourcesMap: map[string]*schema.Resource{
@@ -80,9 +100,11 @@ func Provider() *schema.Provider {
 			"opennebula_datastore":                      dataOpennebulaDatastore(),
 			"opennebula_zone":                           dataOpennebulaZone(),
 			"opennebula_marketplace":                    dataOpennebulaMarketplace(),
+			"opennebula_marketplace_appliance":          dataOpennebulaMarketplaceApp(),
 			"opennebula_virtual_machines":               dataOpennebulaVirtualMachines(),
 			"opennebula_virtual_network_address_range":  dataSourceOpennebulaVirtualNetworkAddressRange(),
 			"opennebula_virtual_network_address_ranges": dataSourceOpennebulaVirtualNetworkAddressRanges(),
+			"opennebula_virtual_network_leases":         dataSourceOpennebulaVirtualNetworkLeases(),
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
@@ -91,8 +113,11 @@ func Provider() *schema.Provider {
 			"opennebula_group_quotas":                     resourceOpennebulaGroupQuotas(),
 			"opennebula_group_admins":                     resourceOpennebulaGroupAdmins(),
 			"opennebula_image":                            resourceOpennebulaImage(),
+			"opennebula_image_snapshot":                   resourceOpennebulaImageSnapshot(),
 			"opennebula_security_group":                   resourceOpennebulaSecurityGroup(),
+			"opennebula_security_group_rule":              resourceOpennebulaSecurityGroupRule(),
 			"opennebula_template":                         resourceOpennebulaTemplate(),
+			"opennebula_template_clone":                   resourceOpennebulaTemplateClone(),
 			"opennebula_user":                             resourceOpennebulaUser(),
 			"opennebula_user_quotas":                      resourceOpennebulaUserQuotas(),
 			"opennebula_virtual_data_center":              resourceOpennebulaVirtualDataCenter(),
@@ -106,11 +131,18 @@ func Provider() *schema.Provider {
 			"opennebula_virtual_router":                   resourceOpennebulaVirtualRouter(),
 			"opennebula_virtual_router_nic":               resourceOpennebulaVirtualRouterNIC(),
 			"opennebula_virtual_network_address_range":    resourceOpennebulaVirtualNetworkAddressRange(),
+			"opennebula_virtual_network_ip_reservation":   resourceOpennebulaVirtualNetworkIPReservation(),
+			"opennebula_virtual_network_reservation":      resourceOpennebulaVirtualNetworkReservation(),
+			"opennebula_virtual_network_template":         resourceOpennebulaVirtualNetworkTemplate(),
 			"opennebula_cluster":                          resourceOpennebulaCluster(),
+			"opennebula_cluster_datastore":                resourceOpennebulaClusterDatastore(),
+			"opennebula_cluster_host":                     resourceOpennebulaClusterHost(),
+			"opennebula_cluster_virtual_network":          resourceOpennebulaClusterVirtualNetwork(),
 			"opennebula_host":                             resourceOpennebulaHost(),
 			"opennebula_datastore":                        resourceOpennebulaDatastore(),
 			"opennebula_marketplace":                      resourceOpennebulaMarketPlace(),
 			"opennebula_marketplace_appliance":            resourceOpennebulaMarketPlaceApp(),
+			"opennebula_marketplace_appliance_export":     resourceOpennebulaMarketPlaceAppExport(),
 		},
 
 		ConfigureContextFunc: providerConfigure,
@@ -124,6 +156,7 @@ type Configuration struct {
 	defaultTags    map[string]interface{}
 	oldDefaultTags map[string]interface{}
 	newDefaultTags map[string]interface{}
+	imageUpload    *imageUploadConfig
 }
 
 func (c *Configuration) isFlowConfigured() bool {
@@ -213,6 +246,15 @@ func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}
 		}
 	}
 
+	imageUpload := d.Get("image_upload").([]interface{})
+	if len(imageUpload) > 0 {
+		imageUploadMap := imageUpload[0].(map[string]interface{})
+		cfg.imageUpload = &imageUploadConfig{
+			listenAddress: imageUploadMap["listen_address"].(string),
+			url:           imageUploadMap["url"].(string),
+		}
+	}
+
 	flowEndpoint, ok := d.GetOk("flow_endpoint")
 	if ok {
 		flowClient := goca.NewFlowClient(
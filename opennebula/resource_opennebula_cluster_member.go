@@ -0,0 +1,216 @@
+package opennebula
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/schemas/cluster"
+)
+
+// clusterMember describes a kind of object that can be added to a cluster
+type clusterMember struct {
+	// entity is the name of the member type used in messages
+	entity string
+	// attribute is the name of the member ID attribute
+	attribute string
+	add       func(cc *goca.ClusterController, id int) error
+	del       func(cc *goca.ClusterController, id int) error
+	ids       func(c *cluster.Cluster) []int
+}
+
+var clusterDatastoreMember = clusterMember{
+	entity:    "datastore",
+	attribute: "datastore_id",
+	add:       func(cc *goca.ClusterController, id int) error { return cc.AddDatastore(id) },
+	del:       func(cc *goca.ClusterController, id int) error { return cc.DelDatastore(id) },
+	ids:       func(c *cluster.Cluster) []int { return c.Datastores.ID },
+}
+
+var clusterHostMember = clusterMember{
+	entity:    "host",
+	attribute: "host_id",
+	add:       func(cc *goca.ClusterController, id int) error { return cc.AddHost(id) },
+	del:       func(cc *goca.ClusterController, id int) error { return cc.DelHost(id) },
+	ids:       func(c *cluster.Cluster) []int { return c.Hosts.ID },
+}
+
+var clusterVirtualNetworkMember = clusterMember{
+	entity:    "virtual network",
+	attribute: "virtual_network_id",
+	add:       func(cc *goca.ClusterController, id int) error { return cc.AddVnet(id) },
+	del:       func(cc *goca.ClusterController, id int) error { return cc.DelVnet(id) },
+	ids:       func(c *cluster.Cluster) []int { return c.Vnets.ID },
+}
+
+func resourceOpennebulaClusterDatastore() *schema.Resource {
+	return clusterMemberResource(clusterDatastoreMember)
+}
+
+func resourceOpennebulaClusterHost() *schema.Resource {
+	return clusterMemberResource(clusterHostMember)
+}
+
+func resourceOpennebulaClusterVirtualNetwork() *schema.Resource {
+	return clusterMemberResource(clusterVirtualNetworkMember)
+}
+
+// clusterMemberResource builds a resource owning the membership of a single object in a cluster
+func clusterMemberResource(member clusterMember) *schema.Resource {
+	return &schema.Resource{
+		CreateContext: func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+			return resourceOpennebulaClusterMemberCreate(ctx, d, meta, member)
+		},
+		ReadContext: func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+			return resourceOpennebulaClusterMemberRead(ctx, d, meta, member)
+		},
+		DeleteContext: func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+			return resourceOpennebulaClusterMemberDelete(ctx, d, meta, member)
+		},
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"cluster_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the cluster",
+			},
+			member.attribute: {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: fmt.Sprintf("ID of the %s to add to the cluster", member.entity),
+			},
+		},
+	}
+}
+
+// parseClusterMemberID parses an ID in the cluster_id:member_id format
+func parseClusterMemberID(id string) (int, int, error) {
+	parts := strings.Split(id, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("Invalid ID format %q. Expected: cluster_id:member_id", id)
+	}
+
+	clusterID, err := strconv.ParseInt(parts[0], 10, 0)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Failed to parse cluster ID: %s", err)
+	}
+
+	memberID, err := strconv.ParseInt(parts[1], 10, 0)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Failed to parse member ID: %s", err)
+	}
+
+	return int(clusterID), int(memberID), nil
+}
+
+func resourceOpennebulaClusterMemberCreate(ctx context.Context, d *schema.ResourceData, meta interface{}, member clusterMember) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	clusterID := d.Get("cluster_id").(int)
+	memberID := d.Get(member.attribute).(int)
+
+	err := member.add(controller.Cluster(clusterID), memberID)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Failed to add the %s to the cluster", member.entity),
+			Detail:   fmt.Sprintf("cluster (ID: %d) %s (ID: %d): %s", clusterID, member.entity, memberID, err),
+		})
+		return diags
+	}
+
+	d.SetId(fmt.Sprintf("%d:%d", clusterID, memberID))
+
+	log.Printf("[INFO] Successfully added %s %d to cluster %d\n", member.entity, memberID, clusterID)
+
+	return resourceOpennebulaClusterMemberRead(ctx, d, meta, member)
+}
+
+func resourceOpennebulaClusterMemberRead(ctx context.Context, d *schema.ResourceData, meta interface{}, member clusterMember) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	clusterID, memberID, err := parseClusterMemberID(d.Id())
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Failed to parse the cluster %s ID", member.entity),
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
+	clusterInfos, err := controller.Cluster(clusterID).Info()
+	if err != nil {
+		if NoExists(err) {
+			log.Printf("[WARN] Removing cluster %s %s from state because the cluster no longer exists", member.entity, d.Id())
+			d.SetId("")
+			return nil
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("cluster (ID: %d): %s", clusterID, err),
+		})
+		return diags
+	}
+
+	found := false
+	for _, id := range member.ids(clusterInfos) {
+		if id == memberID {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		log.Printf("[WARN] Removing cluster %s %s from state because the %s is no longer part of the cluster", member.entity, d.Id(), member.entity)
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("cluster_id", clusterID)
+	d.Set(member.attribute, memberID)
+
+	return nil
+}
+
+func resourceOpennebulaClusterMemberDelete(ctx context.Context, d *schema.ResourceData, meta interface{}, member clusterMember) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	clusterID := d.Get("cluster_id").(int)
+	memberID := d.Get(member.attribute).(int)
+
+	err := member.del(controller.Cluster(clusterID), memberID)
+	if err != nil && !NoExists(err) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Failed to remove the %s from the cluster", member.entity),
+			Detail:   fmt.Sprintf("cluster (ID: %d) %s (ID: %d): %s", clusterID, member.entity, memberID, err),
+		})
+		return diags
+	}
+
+	log.Printf("[INFO] Successfully removed %s %d from cluster %d\n", member.entity, memberID, clusterID)
+
+	return nil
+}
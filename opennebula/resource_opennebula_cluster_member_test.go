@@ -0,0 +1,68 @@
+package opennebula
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestParseClusterMemberID(t *testing.T) {
+	clusterID, memberID, err := parseClusterMemberID("100:42")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if clusterID != 100 || memberID != 42 {
+		t.Fatalf("expected 100:42, got %d:%d", clusterID, memberID)
+	}
+
+	for _, id := range []string{"100", "100:42:1", "a:42", "100:b"} {
+		_, _, err := parseClusterMemberID(id)
+		if err == nil {
+			t.Fatalf("expected an error for ID %q", id)
+		}
+	}
+}
+
+func TestAccClusterDatastore(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckDatastoreDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccClusterDatastoreConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("opennebula_cluster_datastore.test", "cluster_id", "opennebula_cluster.test", "id"),
+					resource.TestCheckResourceAttrPair("opennebula_cluster_datastore.test", "datastore_id", "opennebula_datastore.test", "id"),
+					resource.TestCheckResourceAttr("opennebula_datastore.test", "enabled", "false"),
+				),
+			},
+			{
+				ResourceName:      "opennebula_cluster_datastore.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+var testAccClusterDatastoreConfig = `
+resource "opennebula_cluster" "test" {
+	name = "test-cluster-datastore"
+}
+
+resource "opennebula_datastore" "test" {
+	name    = "test-cluster-datastore"
+	type    = "system"
+	enabled = false
+
+	custom {
+		transfer = "dummy"
+	}
+}
+
+resource "opennebula_cluster_datastore" "test" {
+	cluster_id   = opennebula_cluster.test.id
+	datastore_id = opennebula_datastore.test.id
+}
+`
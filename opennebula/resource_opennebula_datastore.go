@@ -19,7 +19,8 @@ import (
 var datastoreTypes = map[string]string{
 	"IMAGE":  "IMAGE_DS",
 	"SYSTEM": "SYSTEM_DS",
-	"FILE":   "FILE_DS"}
+	"FILE":   "FILE_DS",
+	"BACKUP": "BACKUP_DS"}
 
 func resourceOpennebulaDatastore() *schema.Resource {
 	return &schema.Resource{
@@ -30,8 +31,8 @@ func resourceOpennebulaDatastore() *schema.Resource {
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
 		},
-		CustomizeDiff: SetTagsDiff,
-		Schema: map[string]*schema.Schema{
+		CustomizeDiff: resourceDatastoreCustomizeDiff,
+		Schema: mergeSchemas(map[string]*schema.Schema{
 			"name": {
 				Type:        schema.TypeString,
 				Required:    true,
@@ -41,7 +42,7 @@ func resourceOpennebulaDatastore() *schema.Resource {
 				Type:        schema.TypeString,
 				Required:    true,
 				ForceNew:    true,
-				Description: "Type of the datastore: image, system, files",
+				Description: "Type of the datastore: image, system, file, backup",
 				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
 					value := strings.ToUpper(v.(string))
 
@@ -59,12 +60,19 @@ func resourceOpennebulaDatastore() *schema.Resource {
 			"cluster_ids": {
 				Type:        schema.TypeSet,
 				Optional:    true,
-				Description: "List of cluster IDs hosting the datastore, if not set it uses the default cluster",
+				Computed:    true,
+				Description: "List of cluster IDs hosting the datastore, if not set it uses the default cluster and the membership can be managed with opennebula_cluster_datastore",
 				Elem: &schema.Schema{
 					Type: schema.TypeInt,
 				},
 				MinItems: 1,
 			},
+			"enabled": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Enable the datastore, only system datastores can be disabled",
+			},
 			"restricted_directories": {
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -180,7 +188,7 @@ func resourceOpennebulaDatastore() *schema.Resource {
 						},
 					},
 				},
-				ConflictsWith: []string{"custom"},
+				ConflictsWith: datastoreDriverConflicts("ceph"),
 			},
 			"custom": {
 				Type:     schema.TypeSet,
@@ -201,15 +209,208 @@ func resourceOpennebulaDatastore() *schema.Resource {
 						},
 					},
 				},
-				ConflictsWith: []string{"ceph"},
+				ConflictsWith: datastoreDriverConflicts("custom"),
+			},
+			"filesystem": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Filesystem datastore drivers",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"mode": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ForceNew:     true,
+							Description:  "Transfer mode: shared, ssh, qcow2, local",
+							ValidateFunc: validateDatastoreEnum(datastoreFilesystemModes),
+						},
+					},
+				},
+				ConflictsWith: datastoreDriverConflicts("filesystem"),
+			},
+			"lvm": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "LVM datastore drivers",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"mode": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ForceNew:     true,
+							Default:      "fs_lvm",
+							Description:  "Transfer mode: fs_lvm, fs_lvm_ssh",
+							ValidateFunc: validateDatastoreEnum(datastoreLVMModes),
+						},
+						"thin_enable": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Use LVM thin provisioning",
+						},
+					},
+				},
+				ConflictsWith: datastoreDriverConflicts("lvm"),
+			},
+			"iscsi": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "iSCSI libvirt datastore drivers",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"host": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "iSCSI target host",
+						},
+						"user": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "User for the iSCSI CHAP authentication",
+						},
+						"usage": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Usage of the libvirt secret holding the CHAP password",
+						},
+					},
+				},
+				ConflictsWith: datastoreDriverConflicts("iscsi"),
+			},
+			"restic": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Restic backup datastore drivers",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"password": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Sensitive:   true,
+							Description: "Password of the restic repository",
+						},
+						"sftp_server": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "SFTP server hosting the restic repository",
+						},
+						"sftp_user": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "User to connect to the SFTP server",
+						},
+						"compression": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "Compression mode: off, auto, max",
+							ValidateFunc: validateDatastoreEnum(datastoreResticCompressions),
+						},
+					},
+				},
+				ConflictsWith: datastoreDriverConflicts("restic"),
+			},
+			"rsync": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Rsync backup datastore drivers",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"host": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Rsync server host",
+						},
+						"user": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "User to connect to the rsync server",
+						},
+					},
+				},
+				ConflictsWith: datastoreDriverConflicts("rsync"),
 			},
 			"tags":         tagsSchema(),
 			"default_tags": defaultTagsSchemaComputed(),
 			"tags_all":     tagsSchemaComputed(),
 		},
+			datastoreCapacityFields()),
 	}
 }
 
+func datastoreCapacityFields() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"total_mb": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "Total capacity of the datastore in MB",
+		},
+		"free_mb": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "Free capacity of the datastore in MB",
+		},
+		"used_mb": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "Used capacity of the datastore in MB",
+		},
+		"image_ids": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "IDs of the images stored in the datastore",
+			Elem: &schema.Schema{
+				Type: schema.TypeInt,
+			},
+		},
+	}
+}
+
+func flattenDatastoreCapacity(d *schema.ResourceData, ds *datastore.Datastore) error {
+	d.Set("total_mb", ds.TotalMB)
+	d.Set("free_mb", ds.FreeMB)
+	d.Set("used_mb", ds.UsedMB)
+
+	return d.Set("image_ids", ds.Images.ID)
+}
+
+// resourceDatastoreCustomizeDiff checks that the driver block and the enabled state match the datastore type
+func resourceDatastoreCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	err := SetTagsDiff(ctx, diff, meta)
+	if err != nil {
+		return err
+	}
+
+	dsType := strings.ToUpper(diff.Get("type").(string))
+
+	driverBlock := ""
+	for block, dsTypes := range datastoreDriverBlocks {
+		if len(diff.Get(block).([]interface{})) == 0 {
+			continue
+		}
+		driverBlock = block
+
+		if !contains(dsType, dsTypes) {
+			return fmt.Errorf("%s block can't be used with a datastore of type %s, allowed types: %s", block, dsType, strings.Join(dsTypes, ","))
+		}
+	}
+
+	if !diff.Get("enabled").(bool) && dsType != "SYSTEM" {
+		return fmt.Errorf("a datastore of type %s can't be disabled, only SYSTEM datastores can", dsType)
+	}
+
+	if dsType == "BACKUP" && driverBlock == "" && len(diff.Get("custom").(*schema.Set).List()) == 0 {
+		return fmt.Errorf("a datastore of type BACKUP requires a restic, rsync or custom block")
+	}
+
+	return nil
+}
+
 func getDatastoreController(d *schema.ResourceData, meta interface{}) (*goca.DatastoreController, error) {
 	config := meta.(*Configuration)
 	controller := config.Controller
@@ -320,6 +521,8 @@ func resourceOpennebulaDatastoreCreate(ctx context.Context, d *schema.ResourceDa
 
 		addCephAttributes(cephAttrsMap, tpl)
 
+	} else if block, attrs := getDatastoreDriverBlock(d); attrs != nil {
+		addDatastoreDriverAttributes(block, dsType, attrs, tpl)
 	} else if len(customAttrsList) > 0 {
 		customAttrsMap := customAttrsList[0].(map[string]interface{})
 		datastoreDriver, _ := customAttrsMap["datastore"]
@@ -382,6 +585,18 @@ func resourceOpennebulaDatastoreCreate(ctx context.Context, d *schema.ResourceDa
 		}
 	}
 
+	if !d.Get("enabled").(bool) {
+		err = controller.Datastore(datastoreID).Enable(false)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to disable the datastore",
+				Detail:   fmt.Sprintf("datastore (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
 	return resourceOpennebulaDatastoreRead(ctx, d, meta)
 }
 
@@ -463,6 +678,12 @@ func resourceOpennebulaDatastoreRead(ctx context.Context, d *schema.ResourceData
 	}
 
 	d.Set("name", datastoreInfos.Name)
+
+	state, err := datastoreInfos.State()
+	if err == nil {
+		d.Set("enabled", state != datastore.Disable)
+	}
+
 	dsTypeTpl, err := datastoreInfos.Template.Get(dsKey.Type)
 	var dsType string
 	for k, v := range datastoreTypes {
@@ -502,6 +723,16 @@ func resourceOpennebulaDatastoreRead(ctx context.Context, d *schema.ResourceData
 		return diags
 	}
 
+	err = flattenDatastoreCapacity(d, datastoreInfos)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to set image_ids field",
+			Detail:   fmt.Sprintf("datastore (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
 	restrictedDirs, err := datastoreInfos.Template.Get(dsKey.RestrictedDirs)
 	if err == nil {
 		d.Set("restricted_directories", restrictedDirs)
@@ -602,6 +833,9 @@ func resourceOpennebulaDatastoreRead(ctx context.Context, d *schema.ResourceData
 		}
 
 		d.Set("ceph", []interface{}{cephAttrsMap})
+	} else if block, attrs := getDatastoreDriverBlock(d); attrs != nil {
+
+		d.Set(block, []interface{}{flattenDatastoreDriverAttributes(block, datastoreInfos, attrs)})
 	} else if len(customAttrsList) > 0 {
 
 		customMap := map[string]interface{}{
@@ -737,6 +971,21 @@ func resourceOpennebulaDatastoreUpdate(ctx context.Context, d *schema.ResourceDa
 		}
 	}
 
+	if d.HasChange("enabled") {
+		enabled := d.Get("enabled").(bool)
+
+		err = dc.Enable(enabled)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to change the datastore state",
+				Detail:   fmt.Sprintf("datastore (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+		log.Printf("[INFO] Successfully set enabled to %t for datastore %s\n", enabled, d.Id())
+	}
+
 	update := false
 	newTpl := datastoreInfos.Template
 
@@ -819,6 +1068,11 @@ func resourceOpennebulaDatastoreUpdate(ctx context.Context, d *schema.ResourceDa
 		update = true
 	}
 
+	if block, attrs := getDatastoreDriverBlock(d); attrs != nil && d.HasChange(block) {
+		updateDatastoreDriverAttributes(block, attrs, &newTpl)
+		update = true
+	}
+
 	if d.HasChange("ceph") {
 		cephAttrsList := d.Get("ceph").(*schema.Set).List()
 		addCephAttributes(cephAttrsList[0].(map[string]interface{}), &newTpl)
@@ -2,6 +2,7 @@ package opennebula
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"testing"
 
@@ -47,6 +48,37 @@ func TestAccDatastore(t *testing.T) {
 	})
 }
 
+func TestAccDatastoreBackup(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckDatastoreDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDatastoreConfigRsync,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_datastore.backup", "name", "test-backup"),
+					resource.TestCheckResourceAttr("opennebula_datastore.backup", "type", "backup"),
+					resource.TestCheckResourceAttr("opennebula_datastore.backup", "rsync.#", "1"),
+					resource.TestCheckResourceAttr("opennebula_datastore.backup", "rsync.0.host", "localhost"),
+					resource.TestCheckResourceAttr("opennebula_datastore.backup", "rsync.0.user", "oneadmin"),
+				),
+			},
+			{
+				Config: testAccDatastoreConfigRsyncUpdate,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_datastore.backup", "rsync.0.host", "127.0.0.1"),
+					resource.TestCheckResourceAttr("opennebula_datastore.backup", "rsync.0.user", "oneadmin"),
+				),
+			},
+			{
+				Config:      testAccDatastoreConfigRsyncDisabled,
+				ExpectError: regexp.MustCompile("only SYSTEM datastores can"),
+			},
+		},
+	})
+}
+
 func testAccCheckDatastoreDestroy(s *terraform.State) error {
 	config := testAccProvider.Meta().(*Configuration)
 	controller := config.Controller
@@ -103,3 +135,40 @@ resource "opennebula_datastore" "example" {
 	  }
   }
 `
+
+var testAccDatastoreConfigRsync = `
+resource "opennebula_datastore" "backup" {
+	name = "test-backup"
+	type = "backup"
+
+	rsync {
+		host = "localhost"
+		user = "oneadmin"
+	}
+}
+`
+
+var testAccDatastoreConfigRsyncUpdate = `
+resource "opennebula_datastore" "backup" {
+	name = "test-backup"
+	type = "backup"
+
+	rsync {
+		host = "127.0.0.1"
+		user = "oneadmin"
+	}
+}
+`
+
+var testAccDatastoreConfigRsyncDisabled = `
+resource "opennebula_datastore" "backup" {
+	name    = "test-backup"
+	type    = "backup"
+	enabled = false
+
+	rsync {
+		host = "127.0.0.1"
+		user = "oneadmin"
+	}
+}
+`
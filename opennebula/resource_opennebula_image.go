@@ -34,12 +34,13 @@ func resourceOpennebulaImage() *schema.Resource {
 		DeleteContext: resourceOpennebulaImageDelete,
 		Timeouts: &schema.ResourceTimeout{
 			Create: schema.DefaultTimeout(defaultImageTimeout),
+			Update: schema.DefaultTimeout(defaultImageTimeout),
 			Delete: schema.DefaultTimeout(defaultImageTimeout),
 		},
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
 		},
-		CustomizeDiff: SetTagsDiff,
+		CustomizeDiff: resourceImageCustomizeDiff,
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:        schema.TypeString,
@@ -107,8 +108,7 @@ func resourceOpennebulaImage() *schema.Resource {
 			"datastore_id": {
 				Type:        schema.TypeInt,
 				Required:    true,
-				ForceNew:    true,
-				Description: "ID of the datastore where Image will be stored",
+				Description: "ID of the datastore where Image will be stored, a change moves the image",
 			},
 			"persistent": {
 				Type:        schema.TypeBool,
@@ -125,6 +125,62 @@ func resourceOpennebulaImage() *schema.Resource {
 				Description:   "Path to the new image (local path on the OpenNebula server or URL)",
 				ConflictsWith: []string{"clone_from_image"},
 			},
+			"source_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				Description:   "Local file to upload, served by the provider until the image is READY",
+				ConflictsWith: []string{"path", "clone_from_image", "size"},
+			},
+			"checksum": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				Description:   "Expected checksum of the image source, in the <algorithm>:<value> format with md5 or sha256",
+				ConflictsWith: []string{"clone_from_image"},
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					_, _, err := parseImageChecksum(v.(string))
+					if err != nil {
+						errors = append(errors, fmt.Errorf("%q: %s", k, err))
+					}
+					return
+				},
+			},
+			"replace_on_source_change": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Replace the image when the checksum of its source changes",
+			},
+			"enabled": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Enable or disable the image, a disabled image can't be used by new VMs",
+			},
+			"force_delete": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Skip the check refusing the deletion of an image used by VMs, and unlock the image before its deletion",
+			},
+			"snapshots": imageSnapshotsSchema(),
+			"flatten_to_snapshot": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     -1,
+				Description: "ID of the snapshot to flatten the image to, all the snapshots are deleted",
+			},
+			"md5": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "MD5 checksum of the image source",
+			},
+			"sha256": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "SHA256 checksum of the image source",
+			},
 			"type": {
 				Type:          schema.TypeString,
 				Optional:      true,
@@ -145,9 +201,8 @@ func resourceOpennebulaImage() *schema.Resource {
 				Type:          schema.TypeInt,
 				Optional:      true,
 				Computed:      true,
-				ForceNew:      true,
 				ConflictsWith: []string{"clone_from_image"},
-				Description:   "Size of the new image in MB",
+				Description:   "Size of the image in MB, a persistent image used by a VM can be grown",
 			},
 			"dev_prefix": {
 				Type:        schema.TypeString,
@@ -171,6 +226,7 @@ func resourceOpennebulaImage() *schema.Resource {
 				Type:        schema.TypeString,
 				Optional:    true,
 				Computed:    true,
+				ForceNew:    true,
 				Description: "Image format, normally 'raw' or 'qcow2'",
 			},
 			"timeout": {
@@ -251,8 +307,23 @@ func resourceOpennebulaImageCreate(ctx context.Context, d *schema.ResourceData,
 		}
 	} else { //Otherwise allocate a new image
 		var err error
+		var fileServer *imageFileServer
 
-		imgDef, err := generateImage(d)
+		// serve the local file until the image is READY
+		if sourceFile, ok := d.GetOk("source_file"); ok {
+			fileServer, err = serveImageFile(config.imageUpload, sourceFile.(string))
+			if err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  "Failed to serve the image file",
+					Detail:   err.Error(),
+				})
+				return diags
+			}
+			defer fileServer.Stop()
+		}
+
+		imgDef, err := generateImage(d, fileServer)
 		if err != nil {
 			diags = append(diags, diag.Diagnostic{
 				Severity: diag.Error,
@@ -323,6 +394,12 @@ func resourceOpennebulaImageCreate(ctx context.Context, d *schema.ResourceData,
 		return diags
 	}
 
+	checksumDiags := verifyImageChecksum(d, ic)
+	diags = append(diags, checksumDiags...)
+	if checksumDiags.HasError() {
+		return diags
+	}
+
 	// update permisions
 	if perms, ok := d.GetOk("permissions"); ok {
 		err = ic.Chmod(permissionUnix(perms.(string)))
@@ -360,6 +437,28 @@ func resourceOpennebulaImageCreate(ctx context.Context, d *schema.ResourceData,
 		}
 	}
 
+	if !d.Get("enabled").(bool) {
+		err = ic.Enable(false)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to disable",
+				Detail:   fmt.Sprintf("image (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+
+		_, err = waitForImageState(ctx, ic, timeout, img.Disabled.String())
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to wait image to be in DISABLED state",
+				Detail:   fmt.Sprintf("image (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
 	if lock, ok := d.GetOk("lock"); ok && lock.(string) != "UNLOCK" {
 
 		var level shared.LockLevel
@@ -384,7 +483,174 @@ func resourceOpennebulaImageCreate(ctx context.Context, d *schema.ResourceData,
 		}
 	}
 
-	return resourceOpennebulaImageRead(ctx, d, meta)
+	return append(diags, resourceOpennebulaImageRead(ctx, d, meta)...)
+}
+
+// verifyImageChecksum compares the checksum of the image source to the expected
+// one, then records it in the image template
+func verifyImageChecksum(d *schema.ResourceData, ic *goca.ImageController) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	algorithm := ""
+	expected := ""
+	if checksum, ok := d.GetOk("checksum"); ok {
+		algorithm, expected, _ = parseImageChecksum(checksum.(string))
+	} else if d.Get("replace_on_source_change").(bool) {
+		algorithm = "sha256"
+	}
+
+	if len(algorithm) == 0 {
+		return nil
+	}
+
+	// an unverified checksum is never recorded
+	value, err := imageSourceChecksum(d.Get("source_file").(string), d.Get("path").(string), algorithm)
+	if err != nil {
+		severity := diag.Warning
+		if len(expected) > 0 {
+			severity = diag.Error
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: severity,
+			Summary:  "Failed to compute the image source checksum",
+			Detail:   fmt.Sprintf("image (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	} else if len(expected) > 0 && value != expected {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Image checksum mismatch",
+			Detail:   fmt.Sprintf("image (ID: %s): expected %s checksum %s, got %s", d.Id(), algorithm, expected, value),
+		})
+		return diags
+	}
+
+	tpl := dyn.NewTemplate()
+	tpl.AddPair(imageChecksumKeys[algorithm], value)
+
+	err = ic.Update(tpl.String(), parameters.Merge)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to update image content",
+			Detail:   fmt.Sprintf("image (ID: %s): %s", d.Id(), err),
+		})
+	}
+
+	return diags
+}
+
+// resourceImageCustomizeDiff replaces the image when the checksum of its source changes
+func resourceImageCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	err := SetTagsDiff(ctx, diff, meta)
+	if err != nil {
+		return err
+	}
+
+	if len(diff.Id()) == 0 {
+		return nil
+	}
+
+	if diff.HasChange("size") {
+		err := imageCustomizeDiffSize(diff, meta)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !diff.Get("replace_on_source_change").(bool) {
+		return nil
+	}
+
+	algorithm := "sha256"
+	if checksum, ok := diff.GetOk("checksum"); ok {
+		algorithm, _, _ = parseImageChecksum(checksum.(string))
+	}
+
+	recorded := diff.Get(algorithm).(string)
+	if len(recorded) == 0 {
+		return nil
+	}
+
+	value, err := imageSourceChecksum(diff.Get("source_file").(string), diff.Get("path").(string), algorithm)
+	if err != nil {
+		log.Printf("[WARN] Image (ID: %s): can't compute the source checksum: %s", diff.Id(), err)
+		return nil
+	}
+
+	if value == recorded {
+		return nil
+	}
+
+	log.Printf("[INFO] Image (ID: %s): source %s checksum changed from %s to %s", diff.Id(), algorithm, recorded, value)
+
+	err = diff.SetNew(algorithm, value)
+	if err != nil {
+		return err
+	}
+
+	return diff.ForceNew(algorithm)
+}
+
+// imageCustomizeDiffSize grows the image in place when it's used by a VM disk,
+// otherwise the image is replaced
+func imageCustomizeDiffSize(diff *schema.ResourceDiff, meta interface{}) error {
+	config := meta.(*Configuration)
+
+	oldSize, newSize := diff.GetChange("size")
+	if newSize.(int) < oldSize.(int) {
+		return fmt.Errorf("image (ID: %s): size can't be decreased from %d to %d MB", diff.Id(), oldSize, newSize)
+	}
+
+	imageID, err := strconv.Atoi(diff.Id())
+	if err != nil {
+		return err
+	}
+
+	image, err := config.Controller.Image(imageID).Info(false)
+	if err != nil {
+		return fmt.Errorf("image (ID: %s): %s", diff.Id(), err)
+	}
+
+	_, _, err = imageVMDisk(config.Controller, image)
+	if err != nil {
+		log.Printf("[INFO] Image (ID: %s): size can't be changed in place, the image is replaced: %s", diff.Id(), err)
+		return diff.ForceNew("size")
+	}
+
+	return nil
+}
+
+// imageVMDisk returns the VM disk using the image, OpenNebula can only grow
+// an image through the disk of the VM using it as a persistent image
+func imageVMDisk(controller *goca.Controller, image *img.Image) (*goca.VMController, int, error) {
+
+	if image.Persistent == nil || *image.Persistent != 1 || len(image.VMs.ID) != 1 {
+		return nil, -1, fmt.Errorf("only a persistent image used by a VM can be grown, through the VM disk resize")
+	}
+
+	vmc := controller.VM(image.VMs.ID[0])
+	vmInfos, err := vmc.Info(false)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	for _, disk := range vmInfos.Template.GetDisks() {
+		imageID, _ := disk.GetI(shared.ImageID)
+		if imageID != image.ID {
+			continue
+		}
+
+		diskID, err := disk.GetI(shared.DiskID)
+		if err != nil {
+			return nil, -1, err
+		}
+
+		return vmc, diskID, nil
+	}
+
+	return nil, -1, fmt.Errorf("no disk of the VM (ID: %d) uses the image", vmc.ID)
 }
 
 func resourceOpennebulaImageClone(d *schema.ResourceData, meta interface{}) (int, error) {
@@ -407,11 +673,11 @@ func resourceOpennebulaImageClone(d *schema.ResourceData, meta interface{}) (int
 	return originalic.Clone(d.Get("name").(string), d.Get("datastore_id").(int))
 }
 
-func waitForImageState(ctx context.Context, ic *goca.ImageController, timeout time.Duration, state ...string) (interface{}, error) {
+func waitForImageState(ctx context.Context, ic *goca.ImageController, timeout time.Duration, targets ...string) (interface{}, error) {
 
 	stateConf := &resource.StateChangeConf{
 		Pending: []string{"anythingelse"},
-		Target:  state,
+		Target:  targets,
 		Refresh: func() (interface{}, string, error) {
 
 			log.Println("Refreshing Image state...")
@@ -432,11 +698,11 @@ func waitForImageState(ctx context.Context, ic *goca.ImageController, timeout ti
 
 			log.Printf("Image (ID:%d, name:%s) is currently in state %v", imgInfos.ID, imgInfos.Name, state.String())
 
-			switch state {
-			case img.Ready:
-				return imgInfos, state.String(), nil
-			case img.Error:
+			switch {
+			case state == img.Error:
 				return imgInfos, state.String(), fmt.Errorf("Image (ID:%d) entered error state.", imgInfos.ID)
+			case contains(state.String(), targets):
+				return imgInfos, state.String(), nil
 			default:
 				return imgInfos, "anythingelse", nil
 			}
@@ -498,6 +764,21 @@ func resourceOpennebulaImageRead(ctx context.Context, d *schema.ResourceData, me
 		d.Set("type", image.Type)
 	}
 
+	state, err := image.State()
+	if err == nil {
+		d.Set("enabled", state != img.Disabled)
+	}
+
+	err = d.Set("snapshots", flattenImageSnapshots(image))
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to set attribute",
+			Detail:   fmt.Sprintf("image (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
 	flattenDiags := flattenImageTemplate(d, meta, &image.Template)
 	for _, diag := range flattenDiags {
 		diags = append(diags, diag)
@@ -553,6 +834,12 @@ func flattenImageTemplate(d *schema.ResourceData, meta interface{}, imageTpl *im
 				d.Set("description", desc)
 			}
 
+		case "MD5":
+			d.Set("md5", pair.Value)
+
+		case "SHA256":
+			d.Set("sha256", pair.Value)
+
 		default:
 		}
 	}
@@ -624,6 +911,58 @@ func resourceOpennebulaImageUpdate(ctx context.Context, d *schema.ResourceData,
 		}
 	}
 
+	if d.HasChange("datastore_id") {
+		config := meta.(*Configuration)
+		datastoreID := d.Get("datastore_id").(int)
+
+		imageID, err := moveImageToDatastore(ctx, config.Controller, image, datastoreID, d.Timeout(schema.TimeoutUpdate))
+		if err != nil {
+			// the original image may already be deleted, the clone replaces it
+			if imageID > -1 {
+				d.SetId(fmt.Sprintf("%v", imageID))
+			}
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to move the image",
+				Detail:   fmt.Sprintf("image (ID: %s) to datastore (ID: %d): %s", d.Id(), datastoreID, err),
+			})
+			return diags
+		}
+		log.Printf("[INFO] Successfully moved Image %s to datastore %d, new image ID: %d\n", image.Name, datastoreID, imageID)
+
+		d.SetId(fmt.Sprintf("%v", imageID))
+		ic = config.Controller.Image(imageID)
+
+		image, err = ic.Info(false)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to retrieve informations",
+				Detail:   fmt.Sprintf("image (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
+	if d.HasChange("size") {
+		config := meta.(*Configuration)
+		size := d.Get("size").(int)
+
+		vmc, diskID, err := imageVMDisk(config.Controller, image)
+		if err == nil {
+			err = vmDiskResize(ctx, vmc, d.Timeout(schema.TimeoutUpdate), diskID, size)
+		}
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to resize the image",
+				Detail:   fmt.Sprintf("image (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+		log.Printf("[INFO] Successfully resized Image %s to %d MB\n", image.Name, size)
+	}
+
 	if d.HasChange("name") {
 		err := ic.Rename(d.Get("name").(string))
 		if err != nil {
@@ -678,6 +1017,35 @@ func resourceOpennebulaImageUpdate(ctx context.Context, d *schema.ResourceData,
 		log.Printf("[INFO] Successfully updated persistent flag for Image %s\n", image.Name)
 	}
 
+	if d.HasChange("enabled") {
+		enabled := d.Get("enabled").(bool)
+		err = ic.Enable(enabled)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to enable/disable",
+				Detail:   fmt.Sprintf("image (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+
+		targetState := img.Disabled.String()
+		if enabled {
+			targetState = img.Ready.String()
+		}
+
+		_, err = waitForImageState(ctx, ic, d.Timeout(schema.TimeoutUpdate), targetState)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Failed to wait image to be in %s state", targetState),
+				Detail:   fmt.Sprintf("image (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+		log.Printf("[INFO] Successfully updated enabled flag for Image %s\n", image.Name)
+	}
+
 	if d.HasChange("type") {
 		if imagetype, ok := d.GetOk("type"); ok {
 			err = ic.Chtype(imagetype.(string))
@@ -693,6 +1061,27 @@ func resourceOpennebulaImageUpdate(ctx context.Context, d *schema.ResourceData,
 		log.Printf("[INFO] Successfully updated Image Type %s\n", image.Name)
 	}
 
+	if d.HasChange("flatten_to_snapshot") {
+		snapshotID := d.Get("flatten_to_snapshot").(int)
+		if snapshotID >= 0 {
+			config := meta.(*Configuration)
+
+			snapshotKey := imageSnapshotKey(image.ID)
+			config.mutex.Lock(snapshotKey)
+			err = ic.SnapshotFlatten(snapshotID)
+			config.mutex.Unlock(snapshotKey)
+			if err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  "Failed to flatten the image to the snapshot",
+					Detail:   fmt.Sprintf("image (ID: %s) snapshot (ID: %d): %s", d.Id(), snapshotID, err),
+				})
+				return diags
+			}
+			log.Printf("[INFO] Successfully flattened Image %s to snapshot %d\n", image.Name, snapshotID)
+		}
+	}
+
 	update := false
 	tpl := image.Template
 
@@ -715,6 +1104,27 @@ func resourceOpennebulaImageUpdate(ctx context.Context, d *schema.ResourceData,
 		update = true
 	}
 
+	// only the template attributes are updated, the format is ForceNew as
+	// the image content isn't converted
+	imageAttributes := map[string]imk.Template{
+		"dev_prefix": imk.DevPrefix,
+		"driver":     imk.Driver,
+		"target":     imk.Target,
+	}
+	for attribute, key := range imageAttributes {
+		if !d.HasChange(attribute) {
+			continue
+		}
+
+		tpl.Del(string(key))
+
+		if val, ok := d.GetOk(attribute); ok {
+			tpl.Add(key, val.(string))
+		}
+
+		update = true
+	}
+
 	if d.HasChange("tags") {
 
 		oldTagsIf, newTagsIf := d.GetChange("tags")
@@ -811,6 +1221,87 @@ func resourceOpennebulaImageUpdate(ctx context.Context, d *schema.ResourceData,
 	return resourceOpennebulaImageRead(ctx, d, meta)
 }
 
+// moveImageToDatastore clones the image into the datastore, then swaps the clone
+// with the original image which is deleted. It returns the ID of the clone, also
+// with an error once the original image is deleted.
+func moveImageToDatastore(ctx context.Context, controller *goca.Controller, image *img.Image, datastoreID int, timeout time.Duration) (int, error) {
+
+	if image.RunningVMs > 0 {
+		return -1, fmt.Errorf("the image is used by %d VMs", image.RunningVMs)
+	}
+	if image.LockInfos != nil {
+		return -1, fmt.Errorf("the image is locked")
+	}
+	// the clone only copies the active content of the image
+	if len(image.Snapshots.Snapshots) > 0 {
+		return -1, fmt.Errorf("the image has %d snapshots that would be lost, flatten or delete them first", len(image.Snapshots.Snapshots))
+	}
+
+	ic := controller.Image(image.ID)
+
+	// the clone name must be unique while both images exist
+	cloneID, err := ic.Clone(fmt.Sprintf("%s-move-%d", image.Name, datastoreID), datastoreID)
+	if err != nil {
+		return -1, fmt.Errorf("can't clone the image: %s", err)
+	}
+	cloneIc := controller.Image(cloneID)
+
+	cleanup := func(err error) (int, error) {
+		delErr := cloneIc.Delete()
+		if delErr != nil {
+			log.Printf("[WARN] Failed to delete image clone (ID: %d): %s", cloneID, delErr)
+		}
+		return -1, fmt.Errorf("clone (ID: %d): %s", cloneID, err)
+	}
+
+	_, err = waitForImageState(ctx, cloneIc, timeout, "READY")
+	if err != nil {
+		return cleanup(err)
+	}
+
+	err = cloneIc.Update(image.Template.String(), parameters.Replace)
+	if err != nil {
+		return cleanup(err)
+	}
+
+	if image.Permissions != nil {
+		err = cloneIc.Chmod(*image.Permissions)
+		if err != nil {
+			return cleanup(err)
+		}
+	}
+
+	err = cloneIc.Chown(image.UID, image.GID)
+	if err != nil {
+		return cleanup(err)
+	}
+
+	if image.Persistent != nil && *image.Persistent == 1 {
+		err = cloneIc.Persistent(true)
+		if err != nil {
+			return cleanup(err)
+		}
+	}
+
+	// swap the images
+	err = ic.Delete()
+	if err != nil {
+		return cleanup(fmt.Errorf("can't delete the original image: %s", err))
+	}
+
+	_, err = waitForImageState(ctx, ic, timeout, "notfound")
+	if err != nil {
+		return cloneID, fmt.Errorf("original image (ID: %d) deletion: %s", image.ID, err)
+	}
+
+	err = cloneIc.Rename(image.Name)
+	if err != nil {
+		return cloneID, fmt.Errorf("can't rename the clone (ID: %d): %s", cloneID, err)
+	}
+
+	return cloneID, nil
+}
+
 func resourceOpennebulaImageDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 
 	var diags diag.Diagnostics
@@ -825,12 +1316,50 @@ func resourceOpennebulaImageDelete(ctx context.Context, d *schema.ResourceData,
 		return diags
 	}
 
+	image, err := ic.Info(false)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("image (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	forceDelete := d.Get("force_delete").(bool)
+
+	if image.RunningVMs > 0 && !forceDelete {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Image is in use",
+			Detail:   fmt.Sprintf("image (ID: %s): used by %d VMs (IDs: %v), set force_delete to try the deletion anyway", d.Id(), image.RunningVMs, image.VMs.ID),
+		})
+		return diags
+	}
+
+	if image.LockInfos != nil && forceDelete {
+		log.Printf("[WARN] Unlocking image %s before its deletion, as force_delete is set", d.Id())
+		err = ic.Unlock()
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to unlock",
+				Detail:   fmt.Sprintf("image (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
 	err = ic.Delete()
 	if err != nil {
+		detail := fmt.Sprintf("image (ID: %s): %s", d.Id(), err)
+		if image.RunningVMs > 0 {
+			detail = fmt.Sprintf("image (ID: %s): OpenNebula refuses to delete an image used by VMs (IDs: %v), detach it first: %s", d.Id(), image.VMs.ID, err)
+		}
 		diags = append(diags, diag.Diagnostic{
 			Severity: diag.Error,
 			Summary:  "Failed to delete",
-			Detail:   fmt.Sprintf("image (ID: %s): %s", d.Id(), err),
+			Detail:   detail,
 		})
 		return diags
 	}
@@ -853,7 +1382,7 @@ func resourceOpennebulaImageDelete(ctx context.Context, d *schema.ResourceData,
 	return nil
 }
 
-func generateImage(d *schema.ResourceData) (string, error) {
+func generateImage(d *schema.ResourceData, fileServer *imageFileServer) (string, error) {
 
 	tpl := image.NewTemplate()
 
@@ -877,6 +1406,28 @@ func generateImage(d *schema.ResourceData) (string, error) {
 		tpl.Add(imk.Path, val.(string))
 	}
 
+	if fileServer != nil {
+		sourceFile := d.Get("source_file").(string)
+
+		// the datastore driver checks the downloaded content against the checksum
+		checksum, err := fileChecksum(sourceFile, "md5")
+		if err != nil {
+			return "", fmt.Errorf("can't compute checksum of %s: %s", sourceFile, err)
+		}
+		log.Printf("[INFO] Image file %s MD5 checksum: %s", sourceFile, checksum)
+
+		tpl.Add(imk.Path, fileServer.URL)
+		tpl.Add("MD5", checksum)
+	} else if val, ok := d.GetOk("checksum"); ok {
+		algorithm, checksum, err := parseImageChecksum(val.(string))
+		if err != nil {
+			return "", err
+		}
+		if algorithm == "md5" {
+			tpl.Add("MD5", checksum)
+		}
+	}
+
 	tplStr := tpl.String()
 	log.Printf("[INFO] Image definition: %s", tplStr)
 
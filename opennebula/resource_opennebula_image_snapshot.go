@@ -0,0 +1,347 @@
+package opennebula
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	img "github.com/OpenNebula/one/src/oca/go/src/goca/schemas/image"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/schemas/shared"
+)
+
+func resourceOpennebulaImageSnapshot() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceOpennebulaImageSnapshotCreate,
+		ReadContext:   resourceOpennebulaImageSnapshotRead,
+		UpdateContext: resourceOpennebulaImageSnapshotUpdate,
+		DeleteContext: resourceOpennebulaImageSnapshotDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceOpennebulaImageSnapshotImportState,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"image_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the image",
+			},
+			"snapshot_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the image snapshot",
+			},
+			"active": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
+				Description: "Revert the image to the snapshot when set to true",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Name of the snapshot",
+			},
+			"date": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Creation date of the snapshot",
+			},
+			"size": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Size of the snapshot in MB",
+			},
+			"parent": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "ID of the parent snapshot",
+			},
+		},
+	}
+}
+
+func imageSnapshotsSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Description: "Snapshots of the image",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"id": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"name": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"date": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"size": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"parent": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"active": {
+					Type:     schema.TypeBool,
+					Computed: true,
+				},
+			},
+		},
+	}
+}
+
+func flattenImageSnapshots(image *img.Image) []map[string]interface{} {
+	snapshots := make([]map[string]interface{}, 0, len(image.Snapshots.Snapshots))
+
+	for _, snapshot := range image.Snapshots.Snapshots {
+		snapshots = append(snapshots, map[string]interface{}{
+			"id":     snapshot.ID,
+			"name":   snapshot.Name,
+			"date":   snapshot.Date,
+			"size":   snapshot.Size,
+			"parent": snapshot.Parent,
+			"active": yesNoToBool(snapshot.Active),
+		})
+	}
+
+	return snapshots
+}
+
+// getImageSnapshot returns the snapshot of the image, nil if not found
+func getImageSnapshot(image *img.Image, snapshotID int) *shared.Snapshot {
+	for i, snapshot := range image.Snapshots.Snapshots {
+		if snapshot.ID == snapshotID {
+			return &image.Snapshots.Snapshots[i]
+		}
+	}
+	return nil
+}
+
+func imageSnapshotKey(imageID int) *SubResourceKey {
+	return &SubResourceKey{
+		Type:    "image",
+		ID:      imageID,
+		SubType: "snapshot",
+	}
+}
+
+func resourceOpennebulaImageSnapshotCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	imageID := d.Get("image_id").(int)
+	snapshotID := d.Get("snapshot_id").(int)
+
+	// avoid concurrent snapshot operations on the same image
+	snapshotKey := imageSnapshotKey(imageID)
+	config.mutex.Lock(snapshotKey)
+	defer config.mutex.Unlock(snapshotKey)
+
+	ic := controller.Image(imageID)
+
+	image, err := ic.Info(false)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("image (ID: %d): %s", imageID, err),
+		})
+		return diags
+	}
+
+	if getImageSnapshot(image, snapshotID) == nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Image snapshot not found",
+			Detail:   fmt.Sprintf("image (ID: %d): snapshot (ID: %d) not found", imageID, snapshotID),
+		})
+		return diags
+	}
+
+	d.SetId(fmt.Sprintf("%d", snapshotID))
+
+	if d.Get("active").(bool) {
+		err = ic.SnapshotRevert(snapshotID)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to revert the image to the snapshot",
+				Detail:   fmt.Sprintf("image (ID: %d) snapshot (ID: %d): %s", imageID, snapshotID, err),
+			})
+			return diags
+		}
+		log.Printf("[INFO] Successfully reverted image %d to snapshot %d\n", imageID, snapshotID)
+	}
+
+	return resourceOpennebulaImageSnapshotRead(ctx, d, meta)
+}
+
+func resourceOpennebulaImageSnapshotRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	imageID := d.Get("image_id").(int)
+
+	snapshotID, err := strconv.ParseInt(d.Id(), 10, 0)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "can't parse image snapshot ID",
+			Detail:   fmt.Sprintf("%s is not an ID: %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	image, err := controller.Image(imageID).Info(false)
+	if err != nil {
+		if NoExists(err) {
+			log.Printf("[WARN] Removing image snapshot %s from state because the image %d no longer exists", d.Id(), imageID)
+			d.SetId("")
+			return nil
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("image (ID: %d): %s", imageID, err),
+		})
+		return diags
+	}
+
+	snapshot := getImageSnapshot(image, int(snapshotID))
+	if snapshot == nil {
+		log.Printf("[WARN] Removing image snapshot %s from state because it no longer exists", d.Id())
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("snapshot_id", snapshot.ID)
+	d.Set("active", yesNoToBool(snapshot.Active))
+	d.Set("name", snapshot.Name)
+	d.Set("date", snapshot.Date)
+	d.Set("size", snapshot.Size)
+	d.Set("parent", snapshot.Parent)
+
+	return nil
+}
+
+func resourceOpennebulaImageSnapshotUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	imageID := d.Get("image_id").(int)
+	snapshotID := d.Get("snapshot_id").(int)
+
+	if d.HasChange("active") && d.Get("active").(bool) {
+
+		snapshotKey := imageSnapshotKey(imageID)
+		config.mutex.Lock(snapshotKey)
+		defer config.mutex.Unlock(snapshotKey)
+
+		err := controller.Image(imageID).SnapshotRevert(snapshotID)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to revert the image to the snapshot",
+				Detail:   fmt.Sprintf("image (ID: %d) snapshot (ID: %d): %s", imageID, snapshotID, err),
+			})
+			return diags
+		}
+		log.Printf("[INFO] Successfully reverted image %d to snapshot %d\n", imageID, snapshotID)
+	}
+
+	return resourceOpennebulaImageSnapshotRead(ctx, d, meta)
+}
+
+func resourceOpennebulaImageSnapshotDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	imageID := d.Get("image_id").(int)
+	snapshotID := d.Get("snapshot_id").(int)
+
+	snapshotKey := imageSnapshotKey(imageID)
+	config.mutex.Lock(snapshotKey)
+	defer config.mutex.Unlock(snapshotKey)
+
+	ic := controller.Image(imageID)
+
+	image, err := ic.Info(false)
+	if err != nil {
+		if NoExists(err) {
+			return nil
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("image (ID: %d): %s", imageID, err),
+		})
+		return diags
+	}
+
+	// OpenNebula refuses to delete the active snapshot, it's the image content
+	snapshot := getImageSnapshot(image, snapshotID)
+	if snapshot != nil && yesNoToBool(snapshot.Active) {
+		log.Printf("[WARN] Image %d snapshot %d is active, it's only removed from the state\n", imageID, snapshotID)
+		return nil
+	}
+
+	err = ic.SnapshotDelete(snapshotID)
+	if err != nil && !NoExists(err) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to delete the image snapshot",
+			Detail:   fmt.Sprintf("image (ID: %d) snapshot (ID: %d): %s", imageID, snapshotID, err),
+		})
+		return diags
+	}
+
+	log.Printf("[INFO] Successfully deleted image %d snapshot %d\n", imageID, snapshotID)
+	return nil
+}
+
+func resourceOpennebulaImageSnapshotImportState(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+
+	fullID := d.Id()
+	parts := strings.Split(fullID, ":")
+
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("Invalid ID format. Expected: image_id:snapshot_id")
+	}
+
+	imageID, err := strconv.ParseInt(parts[0], 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse image ID: %s", err)
+	}
+
+	snapshotID, err := strconv.ParseInt(parts[1], 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse image snapshot ID: %s", err)
+	}
+
+	d.SetId(parts[1])
+	d.Set("image_id", imageID)
+	d.Set("snapshot_id", snapshotID)
+
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,152 @@
+package opennebula
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca/schemas/vm"
+)
+
+func TestAccImageSnapshot(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckImageDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccImageSnapshotNotFound,
+				ExpectError: regexp.MustCompile("Image snapshot not found"),
+			},
+			{
+				Config: testAccImageSnapshotVM,
+				Check: resource.ComposeTestCheckFunc(
+					testAccImageSnapshotTakeDiskSnapshots("opennebula_virtual_machine.vm", 2),
+				),
+			},
+			{
+				// the snapshots are saved in the persistent image when the VM is terminated
+				Config: testAccImageSnapshotImage,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_image.image", "snapshots.#", "2"),
+					resource.TestCheckResourceAttr("opennebula_image.image", "snapshots.1.active", "true"),
+				),
+			},
+			{
+				Config: testAccImageSnapshotRevert,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_image_snapshot.revert", "snapshot_id", "0"),
+					resource.TestCheckResourceAttr("opennebula_image_snapshot.revert", "active", "true"),
+					resource.TestCheckResourceAttr("opennebula_image_snapshot.revert", "name", "terra-snap-0"),
+					resource.TestCheckResourceAttr("opennebula_image_snapshot.old", "snapshot_id", "1"),
+					resource.TestCheckResourceAttr("opennebula_image_snapshot.old", "active", "false"),
+				),
+			},
+			{
+				Config: testAccImageSnapshotDelete,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_image.image", "snapshots.#", "1"),
+					resource.TestCheckResourceAttr("opennebula_image.image", "snapshots.0.id", "0"),
+					resource.TestCheckResourceAttr("opennebula_image.image", "snapshots.0.active", "true"),
+				),
+			},
+		},
+	})
+}
+
+// testAccImageSnapshotTakeDiskSnapshots takes snapshots of the first disk of the VM
+func testAccImageSnapshotTakeDiskSnapshots(resourceName string, count int) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		config := testAccProvider.Meta().(*Configuration)
+		controller := config.Controller
+
+		rs, ok := s.RootModule().Resources[resourceName]
+		if !ok {
+			return fmt.Errorf("resource %s not found", resourceName)
+		}
+
+		vmID, err := strconv.Atoi(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
+		vmc := controller.VM(vmID)
+
+		for i := 0; i < count; i++ {
+			_, err = vmc.Disk(0).Snapshot(fmt.Sprintf("terra-snap-%d", i))
+			if err != nil {
+				return fmt.Errorf("virtual machine (ID: %d): disk snapshot failed: %s", vmID, err)
+			}
+
+			stateConf := NewVMUpdateStateConf(5*time.Minute,
+				NewVMLCMState(vm.DiskSnapshot).ToStrings(),
+				NewVMLCMState(vm.Running).ToStrings(),
+			)
+			_, err = waitForVMStates(context.Background(), vmc, stateConf)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+var testAccImageSnapshotImage = `
+resource "opennebula_image" "image" {
+  name         = "terra-image-snapshot"
+  datastore_id = 1
+  persistent   = true
+  type         = "DATABLOCK"
+  size         = "16"
+  dev_prefix   = "vd"
+  driver       = "qcow2"
+}
+`
+
+var testAccImageSnapshotNotFound = testAccImageSnapshotImage + `
+resource "opennebula_image_snapshot" "snapshot" {
+  image_id    = opennebula_image.image.id
+  snapshot_id = 42
+}
+`
+
+var testAccImageSnapshotVM = testAccImageSnapshotImage + `
+resource "opennebula_virtual_machine" "vm" {
+  name   = "terra-image-snapshot-vm"
+  memory = 128
+  cpu    = 0.1
+
+  disk {
+    image_id = opennebula_image.image.id
+  }
+}
+`
+
+var testAccImageSnapshotRevert = testAccImageSnapshotImage + `
+resource "opennebula_image_snapshot" "revert" {
+  image_id    = opennebula_image.image.id
+  snapshot_id = 0
+  active      = true
+}
+
+resource "opennebula_image_snapshot" "old" {
+  image_id    = opennebula_image.image.id
+  snapshot_id = 1
+
+  depends_on = [opennebula_image_snapshot.revert]
+}
+`
+
+var testAccImageSnapshotDelete = testAccImageSnapshotImage + `
+resource "opennebula_image_snapshot" "revert" {
+  image_id    = opennebula_image.image.id
+  snapshot_id = 0
+  active      = true
+}
+`
@@ -3,7 +3,9 @@ package opennebula
 import (
 	"fmt"
 	"reflect"
+	"regexp"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
@@ -33,6 +35,7 @@ func TestAccImage(t *testing.T) {
 					resource.TestCheckResourceAttr("opennebula_image.testimage", "tags.env", "prod"),
 					resource.TestCheckResourceAttr("opennebula_image.testimage", "tags.customer", "test"),
 					resource.TestCheckResourceAttr("opennebula_image.testimage", "timeout", "5"),
+					resource.TestCheckResourceAttr("opennebula_image.testimage", "snapshots.#", "0"),
 					resource.TestCheckResourceAttrSet("opennebula_image.testimage", "uid"),
 					resource.TestCheckResourceAttrSet("opennebula_image.testimage", "gid"),
 					resource.TestCheckResourceAttrSet("opennebula_image.testimage", "uname"),
@@ -105,6 +108,50 @@ func TestAccImage(t *testing.T) {
 					}, "test-image-datablock"),
 				),
 			},
+			{
+				Config: testAccImageConfigDatablockAttributes,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_image.testimage", "name", "test-image-datablock"),
+					resource.TestCheckResourceAttr("opennebula_image.testimage", "dev_prefix", "sd"),
+					resource.TestCheckResourceAttr("opennebula_image.testimage", "driver", "raw"),
+					resource.TestCheckResourceAttr("opennebula_image.testimage", "format", "raw"),
+					resource.TestCheckResourceAttr("opennebula_image.testimage", "enabled", "false"),
+				),
+			},
+			{
+				// the image isn't used by a VM, it's replaced
+				Config: strings.Replace(testAccImageConfigDatablockAttributes, `size = "128"`, `size = "256"`, 1),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_image.testimage", "size", "256"),
+				),
+			},
+			{
+				Config:      testAccImageConfigDatablockAttributes,
+				ExpectError: regexp.MustCompile("size can't be decreased"),
+			},
+		},
+	})
+}
+
+func TestAccImageChecksum(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckImageDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccImageConfigChecksumReplace,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_image.checksum", "name", "test-image-checksum"),
+					resource.TestCheckResourceAttr("opennebula_image.checksum", "replace_on_source_change", "true"),
+					resource.TestCheckResourceAttr("opennebula_image.checksum", "sha256", ""),
+					resource.TestCheckResourceAttr("opennebula_image.checksum", "md5", ""),
+				),
+			},
+			{
+				Config:      testAccImageConfigChecksum,
+				ExpectError: regexp.MustCompile("can't be computed by the provider"),
+			},
 		},
 	})
 }
@@ -220,3 +267,49 @@ resource "opennebula_image" "testimage" {
    lock = "UNLOCK"
 }
 `
+
+var testAccImageConfigDatablockAttributes = `
+resource "opennebula_image" "testimage" {
+   name = "test-image-datablock"
+   description = "Terraform datablock"
+   datastore_id = 1
+   persistent = false
+   type = "DATABLOCK"
+   size = "128"
+   dev_prefix = "sd"
+   permissions = 660
+   driver = "raw"
+   format = "raw"
+   enabled = false
+   tags = {
+     env = "dev"
+     customer = "test"
+     version = "2"
+   }
+   timeout = 10
+   lock = "UNLOCK"
+}
+`
+
+// the checksum of a datablock can't be computed: it's not recorded, and an expected one fails
+var testAccImageChecksumValue = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+var testAccImageConfigChecksum = `
+resource "opennebula_image" "checksum" {
+   name = "test-image-checksum"
+   datastore_id = 1
+   type = "DATABLOCK"
+   size = "16"
+   checksum = "sha256:` + testAccImageChecksumValue + `"
+}
+`
+
+var testAccImageConfigChecksumReplace = `
+resource "opennebula_image" "checksum" {
+   name = "test-image-checksum"
+   datastore_id = 1
+   type = "DATABLOCK"
+   size = "16"
+   replace_on_source_change = true
+}
+`
@@ -1052,7 +1052,7 @@ func resourceOpennebulaMarketPlaceUpdate(ctx context.Context, d *schema.Resource
 		pendingStates := []string{marketplace.Enabled.String()}
 		targetStates := []string{marketplace.Disabled.String()}
 		// expected states when enabling
-		if disabled {
+		if !disabled {
 			tmp := pendingStates
 			pendingStates = targetStates
 			targetStates = tmp
@@ -720,7 +720,7 @@ func resourceOpennebulaMarketPlaceAppUpdate(ctx context.Context, d *schema.Resou
 		targetStates := []string{app.Disabled.String()}
 
 		// expected states when enabling
-		if disabled {
+		if !disabled {
 			tmp := pendingStates
 			pendingStates = targetStates
 			targetStates = tmp
@@ -0,0 +1,375 @@
+package opennebula
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/parameters"
+	app "github.com/OpenNebula/one/src/oca/go/src/goca/schemas/marketplaceapp"
+	appk "github.com/OpenNebula/one/src/oca/go/src/goca/schemas/marketplaceapp/keys"
+)
+
+func resourceOpennebulaMarketPlaceAppExport() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceOpennebulaMarketPlaceAppExportCreate,
+		ReadContext:   resourceOpennebulaMarketPlaceAppExportRead,
+		DeleteContext: resourceOpennebulaMarketPlaceAppExportDelete,
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(defaultImageTimeout),
+			Delete: schema.DefaultTimeout(defaultImageTimeout),
+		},
+		Schema: map[string]*schema.Schema{
+			"appliance_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the appliance to export",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Name of the exported images and template",
+			},
+			"datastore_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the datastore receiving the images",
+			},
+			"create_template": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				ForceNew:    true,
+				Description: "Create the VM template associated to an IMAGE appliance",
+			},
+			"image_ids": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "IDs of the exported images",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"template_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "ID of the exported VM template, -1 if there is none",
+			},
+		},
+	}
+}
+
+// exportMarketPlaceAppImage creates an image in the datastore from an IMAGE appliance
+func exportMarketPlaceAppImage(controller *goca.Controller, appInfos *app.MarketPlaceApp, name string, datastoreID int) (int, error) {
+
+	appTpl, err := base64.StdEncoding.DecodeString(appInfos.AppTemplate64)
+	if err != nil {
+		return -1, fmt.Errorf("can't decode the image template of appliance %d: %s", appInfos.ID, err)
+	}
+
+	imgTpl := fmt.Sprintf("%s\nNAME = \"%s\"\nFROM_APP = \"%d\"\n", appTpl, name, appInfos.ID)
+
+	imageID, err := controller.Images().Create(imgTpl, uint(datastoreID))
+	if err != nil {
+		return -1, fmt.Errorf("can't export appliance %d: %s", appInfos.ID, err)
+	}
+
+	log.Printf("[INFO] Appliance %d exported as image %d", appInfos.ID, imageID)
+
+	return imageID, nil
+}
+
+// getMarketPlaceAppByName retrieves an appliance of a marketplace from its name
+func getMarketPlaceAppByName(controller *goca.Controller, market, name string) (*app.MarketPlaceApp, error) {
+
+	apps, err := controller.MarketPlaceApps().Info()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, a := range apps.MarketPlaceApps {
+		if a.MarketPlace == market && a.Name == name {
+			return &apps.MarketPlaceApps[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no appliance named %s in marketplace %s", name, market)
+}
+
+// exportMarketPlaceApp exports an appliance, the same way the OpenNebula CLI does:
+// - an IMAGE appliance gives an image, and a VM template using it when the appliance provides one
+// - a VMTEMPLATE appliance gives a VM template, and an image for each disk referencing an appliance
+// The IDs of the resources created are returned even when an error occurs, to allow their cleanup.
+func exportMarketPlaceApp(controller *goca.Controller, appInfos *app.MarketPlaceApp, name string, datastoreID int, createTemplate bool) ([]int, int, error) {
+
+	imageIDs := make([]int, 0)
+	templateID := -1
+
+	switch ApplianceTypeToString(appInfos.Type) {
+	case AppTypeImage:
+
+		imageID, err := exportMarketPlaceAppImage(controller, appInfos, name, datastoreID)
+		if err != nil {
+			return imageIDs, templateID, err
+		}
+		imageIDs = append(imageIDs, imageID)
+
+		vmTpl64, _ := appInfos.Template.GetStr(string(appk.VMTemplate64))
+		if !createTemplate || len(vmTpl64) == 0 {
+			return imageIDs, templateID, nil
+		}
+
+		vmTpl, err := base64.StdEncoding.DecodeString(vmTpl64)
+		if err != nil {
+			return imageIDs, templateID, fmt.Errorf("can't decode the VM template of appliance %d: %s", appInfos.ID, err)
+		}
+
+		tplStr := fmt.Sprintf("%s\nNAME = \"%s\"\nDISK = [ IMAGE_ID = \"%d\" ]\n", vmTpl, name, imageID)
+
+		templateID, err = controller.Templates().Create(tplStr)
+		if err != nil {
+			return imageIDs, -1, fmt.Errorf("can't create the VM template of appliance %d: %s", appInfos.ID, err)
+		}
+
+	case AppTypeVM:
+
+		vmTpl, err := base64.StdEncoding.DecodeString(appInfos.AppTemplate64)
+		if err != nil {
+			return imageIDs, templateID, fmt.Errorf("can't decode the VM template of appliance %d: %s", appInfos.ID, err)
+		}
+
+		templateID, err = controller.Templates().Create(fmt.Sprintf("%s\nNAME = \"%s\"\n", vmTpl, name))
+		if err != nil {
+			return imageIDs, -1, fmt.Errorf("can't create the VM template of appliance %d: %s", appInfos.ID, err)
+		}
+
+		tc := controller.Template(templateID)
+		tpl, err := tc.Info(false, false)
+		if err != nil {
+			return imageIDs, templateID, err
+		}
+
+		// the disks reference appliances of the same marketplace
+		for i, disk := range getTemplateDiskVectors(&tpl.Template.Template) {
+
+			diskAppName, err := disk.GetStr("APP")
+			if err != nil {
+				continue
+			}
+
+			diskApp, err := getMarketPlaceAppByName(controller, appInfos.MarketPlace, diskAppName)
+			if err != nil {
+				return imageIDs, templateID, err
+			}
+
+			imageID, err := exportMarketPlaceAppImage(controller, diskApp, fmt.Sprintf("%s-disk-%d", name, i), datastoreID)
+			if err != nil {
+				return imageIDs, templateID, err
+			}
+			imageIDs = append(imageIDs, imageID)
+
+			disk.Del("APP")
+			disk.AddPair("IMAGE_ID", imageID)
+		}
+
+		err = tc.Update(tpl.Template.String(), parameters.Replace)
+		if err != nil {
+			return imageIDs, templateID, fmt.Errorf("can't update the disks of template %d: %s", templateID, err)
+		}
+
+	default:
+		return imageIDs, templateID, fmt.Errorf("appliance %d: type %s can't be exported", appInfos.ID, ApplianceTypeToString(appInfos.Type))
+	}
+
+	return imageIDs, templateID, nil
+}
+
+func resourceOpennebulaMarketPlaceAppExportCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	var diags diag.Diagnostics
+
+	appID := d.Get("appliance_id").(int)
+
+	ac := controller.MarketPlaceApp(appID)
+	appInfos, err := ac.Info(false)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve appliance informations",
+			Detail:   fmt.Sprintf("marketplace appliance (ID: %d): %s", appID, err),
+		})
+		return diags
+	}
+
+	state, _ := appInfos.StateString()
+	if state != app.Ready.String() {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Appliance can't be exported",
+			Detail:   fmt.Sprintf("marketplace appliance (ID: %d): appliance is in state %s, expected %s", appID, state, app.Ready.String()),
+		})
+		return diags
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to generate an UUID",
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
+	imageIDs, templateID, err := exportMarketPlaceApp(controller, appInfos, d.Get("name").(string), d.Get("datastore_id").(int), d.Get("create_template").(bool))
+
+	// keep track of the created resources to delete them with the resource
+	if len(imageIDs) > 0 || templateID != -1 {
+		d.SetId(id)
+		d.Set("image_ids", imageIDs)
+		d.Set("template_id", templateID)
+	}
+
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to export the appliance",
+			Detail:   fmt.Sprintf("marketplace appliance (ID: %d): %s", appID, err),
+		})
+		return diags
+	}
+
+	timeout := d.Timeout(schema.TimeoutCreate)
+	for _, imageID := range imageIDs {
+		_, err = waitForImageState(ctx, controller.Image(imageID), timeout, "READY")
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to wait image to be in READY state",
+				Detail:   fmt.Sprintf("marketplace appliance (ID: %d): image (ID: %d): %s", appID, imageID, err),
+			})
+			return diags
+		}
+	}
+
+	return resourceOpennebulaMarketPlaceAppExportRead(ctx, d, meta)
+}
+
+func resourceOpennebulaMarketPlaceAppExportRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	var diags diag.Diagnostics
+
+	imageIDs := make([]int, 0)
+	for _, imageIDIf := range d.Get("image_ids").([]interface{}) {
+		imageID := imageIDIf.(int)
+
+		_, err := controller.Image(imageID).Info(false)
+		if err != nil {
+			if NoExists(err) {
+				log.Printf("[WARN] Exported image %d of appliance %d no longer exists", imageID, d.Get("appliance_id"))
+				continue
+			}
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to retrieve informations",
+				Detail:   fmt.Sprintf("image (ID: %d): %s", imageID, err),
+			})
+			return diags
+		}
+		imageIDs = append(imageIDs, imageID)
+	}
+
+	templateID := d.Get("template_id").(int)
+	if templateID != -1 {
+		_, err := controller.Template(templateID).Info(false, false)
+		if err != nil {
+			if !NoExists(err) {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  "Failed to retrieve informations",
+					Detail:   fmt.Sprintf("template (ID: %d): %s", templateID, err),
+				})
+				return diags
+			}
+			log.Printf("[WARN] Exported template %d of appliance %d no longer exists", templateID, d.Get("appliance_id"))
+			templateID = -1
+		}
+	}
+
+	if len(imageIDs) == 0 && templateID == -1 {
+		log.Printf("[WARN] Removing appliance export %s from state because its resources no longer exist", d.Id())
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("image_ids", imageIDs)
+	d.Set("template_id", templateID)
+
+	return nil
+}
+
+func resourceOpennebulaMarketPlaceAppExportDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	var diags diag.Diagnostics
+
+	templateID := d.Get("template_id").(int)
+	if templateID != -1 {
+		err := controller.Template(templateID).Delete()
+		if err != nil && !NoExists(err) {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to delete the exported template",
+				Detail:   fmt.Sprintf("template (ID: %d): %s", templateID, err),
+			})
+			return diags
+		}
+	}
+
+	timeout := d.Timeout(schema.TimeoutDelete)
+	for _, imageIDIf := range d.Get("image_ids").([]interface{}) {
+		imageID := imageIDIf.(int)
+		ic := controller.Image(imageID)
+
+		err := ic.Delete()
+		if err != nil {
+			if NoExists(err) {
+				continue
+			}
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to delete the exported image",
+				Detail:   fmt.Sprintf("image (ID: %d): %s", imageID, err),
+			})
+			return diags
+		}
+
+		_, err = waitForImageState(ctx, ic, timeout, "notfound")
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to wait image to be in NOTFOUND state",
+				Detail:   fmt.Sprintf("image (ID: %d): %s", imageID, err),
+			})
+			return diags
+		}
+	}
+
+	log.Printf("[INFO] Successfully deleted appliance export %s\n", d.Id())
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package opennebula
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	img "github.com/OpenNebula/one/src/oca/go/src/goca/schemas/image"
+)
+
+// name of a small IMAGE appliance of the OpenNebula public marketplace
+var testAccMarketplaceAppExportName = "Ttylinux - KVM"
+
+func TestAccMarketplaceApplianceExport(t *testing.T) {
+	if os.Getenv(resource.EnvTfAcc) == "" {
+		t.Skipf("Acceptance tests skipped unless env '%s' set", resource.EnvTfAcc)
+	}
+	testAccPreCheck(t)
+
+	// the appliance is looked up before building the step config, the provider
+	// is configured from the environment for this purpose
+	diags := testAccProvider.Configure(context.Background(), terraform.NewResourceConfigRaw(nil))
+	if diags.HasError() {
+		t.Fatalf("failed to configure the provider: %v", diags)
+	}
+
+	app, err := getMarketPlaceAppByName(testAccProvider.Meta().(*Configuration).Controller, "OpenNebula Public", testAccMarketplaceAppExportName)
+	if err != nil {
+		t.Skipf("public marketplace appliance not available: %s", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckMarketplaceApplianceExportDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccMarketplaceApplianceExportConfig, app.ID),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_marketplace_appliance_export.example", "image_ids.#", "1"),
+					resource.TestCheckResourceAttrSet("opennebula_marketplace_appliance_export.example", "template_id"),
+					testAccCheckMarketplaceApplianceExportImages("opennebula_marketplace_appliance_export.example"),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckMarketplaceApplianceExportImages(resourceName string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		controller := testAccProvider.Meta().(*Configuration).Controller
+
+		rs, ok := s.RootModule().Resources[resourceName]
+		if !ok {
+			return fmt.Errorf("resource %s not found", resourceName)
+		}
+
+		imageID, _ := strconv.ParseUint(rs.Primary.Attributes["image_ids.0"], 10, 0)
+		image, err := controller.Image(int(imageID)).Info(false)
+		if err != nil {
+			return err
+		}
+
+		if image.DatastoreID != 1 {
+			return fmt.Errorf("expected image %d in datastore 1, got %d", imageID, image.DatastoreID)
+		}
+
+		state, _ := image.State()
+		if state != img.Ready {
+			return fmt.Errorf("expected image %d to be READY, got %s", imageID, state.String())
+		}
+
+		return nil
+	}
+}
+
+func testAccCheckMarketplaceApplianceExportDestroy(s *terraform.State) error {
+	controller := testAccProvider.Meta().(*Configuration).Controller
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "opennebula_marketplace_appliance_export" {
+			continue
+		}
+
+		imageID, _ := strconv.ParseUint(rs.Primary.Attributes["image_ids.0"], 10, 0)
+		_, err := controller.Image(int(imageID)).Info(false)
+		if !NoExists(err) {
+			return fmt.Errorf("Expected image %d to have been destroyed", imageID)
+		}
+
+		templateID, _ := strconv.ParseInt(rs.Primary.Attributes["template_id"], 10, 0)
+		if templateID != -1 {
+			_, err = controller.Template(int(templateID)).Info(false, false)
+			if !NoExists(err) {
+				return fmt.Errorf("Expected template %d to have been destroyed", templateID)
+			}
+		}
+	}
+
+	return nil
+}
+
+var testAccMarketplaceApplianceExportConfig = `
+resource "opennebula_marketplace_appliance_export" "example" {
+  appliance_id = %d
+  name         = "test-app-export"
+  datastore_id = 1
+}
+`
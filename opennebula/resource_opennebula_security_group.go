@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -16,6 +17,8 @@ import (
 	sgk "github.com/OpenNebula/one/src/oca/go/src/goca/schemas/securitygroup/keys"
 )
 
+var defaultSecurityGroupCommitTimeout = 10 * time.Minute
+
 func resourceOpennebulaSecurityGroup() *schema.Resource {
 	return &schema.Resource{
 		CreateContext: resourceOpennebulaSecurityGroupCreate,
@@ -26,7 +29,10 @@ func resourceOpennebulaSecurityGroup() *schema.Resource {
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
 		},
-		CustomizeDiff: SetTagsDiff,
+		Timeouts: &schema.ResourceTimeout{
+			Update: schema.DefaultTimeout(defaultSecurityGroupCommitTimeout),
+		},
+		CustomizeDiff: resourceSecurityGroupCustomizeDiff,
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:        schema.TypeString,
@@ -87,72 +93,12 @@ func resourceOpennebulaSecurityGroup() *schema.Resource {
 			},
 			"rule": {
 				Type:        schema.TypeList,
-				Required:    true,
+				Optional:    true,
+				Computed:    true,
 				MinItems:    1,
-				Description: "List of rules to be in the Security Group",
+				Description: "List of rules to be in the Security Group, if not set the rules can be managed with opennebula_security_group_rule",
 				Elem: &schema.Resource{
-					Schema: map[string]*schema.Schema{
-						"protocol": {
-							Type:        schema.TypeString,
-							Description: "Protocol for the rule, must be one of: ALL, TCP, UDP, ICMP or IPSEC",
-							Required:    true,
-							ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
-								validprotos := []string{"ALL", "TCP", "UDP", "ICMP", "IPSEC"}
-								value := v.(string)
-
-								if !contains(value, validprotos) {
-									errors = append(errors, fmt.Errorf("Protocol %q must be one of: %s", k, strings.Join(validprotos, ",")))
-								}
-
-								return
-							},
-						},
-						"rule_type": {
-							Type:        schema.TypeString,
-							Description: "Direction of the traffic flow to allow, must be INBOUND or OUTBOUND",
-							Required:    true,
-							ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
-								validtypes := []string{"INBOUND", "OUTBOUND"}
-								value := v.(string)
-
-								if !contains(value, validtypes) {
-									errors = append(errors, fmt.Errorf("Rule type %q must be one of: %s", k, strings.Join(validtypes, ",")))
-								}
-
-								return
-							},
-						},
-						"ip": {
-							Type:        schema.TypeString,
-							Description: "IP (or starting IP if used with 'size') to apply the rule to",
-							Optional:    true,
-							Computed:    true,
-						},
-						"size": {
-							Type:        schema.TypeString,
-							Description: "Number of IPs to apply the rule from, starting with 'ip'",
-							Optional:    true,
-							Computed:    true,
-						},
-						"range": {
-							Type:        schema.TypeString,
-							Description: "Comma separated list of ports and port ranges",
-							Optional:    true,
-							Computed:    true,
-						},
-						"icmp_type": {
-							Type:        schema.TypeString,
-							Description: "Type of ICMP traffic to apply to when 'protocol' is ICMP",
-							Optional:    true,
-							Computed:    true,
-						},
-						"network_id": {
-							Type:        schema.TypeString,
-							Description: "VNET ID to be used as the source/destination IP addresses",
-							Optional:    true,
-							Computed:    true,
-						},
-					},
+					Schema: securityGroupRuleFields(),
 				},
 			},
 			"commit": {
@@ -161,6 +107,16 @@ func resourceOpennebulaSecurityGroup() *schema.Resource {
 				Optional:    true,
 				Default:     true,
 			},
+			"wait_for_commit": {
+				Type:        schema.TypeBool,
+				Description: "Wait for the commit of the rules to be propagated to all the Virtual Machines, fails if some of them are in error",
+				Optional:    true,
+				Default:     false,
+			},
+			"updated_vms":  securityGroupVMsSchema("IDs of the Virtual Machines with up to date rules"),
+			"outdated_vms": securityGroupVMsSchema("IDs of the Virtual Machines waiting for the rules to be updated"),
+			"updating_vms": securityGroupVMsSchema("IDs of the Virtual Machines with the rules being updated"),
+			"error_vms":    securityGroupVMsSchema("IDs of the Virtual Machines which failed to update the rules"),
 			"group": {
 				Type:          schema.TypeString,
 				Optional:      true,
@@ -175,6 +131,87 @@ func resourceOpennebulaSecurityGroup() *schema.Resource {
 	}
 }
 
+func securityGroupVMsSchema(description string) *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Description: description,
+		Elem: &schema.Schema{
+			Type: schema.TypeInt,
+		},
+	}
+}
+
+func securityGroupRuleFields() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"protocol": {
+			Type:        schema.TypeString,
+			Description: "Protocol for the rule, must be one of: ALL, TCP, UDP, ICMP or IPSEC",
+			Required:    true,
+			ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+				validprotos := []string{"ALL", "TCP", "UDP", "ICMP", "IPSEC"}
+				value := v.(string)
+
+				if !contains(value, validprotos) {
+					errors = append(errors, fmt.Errorf("Protocol %q must be one of: %s", k, strings.Join(validprotos, ",")))
+				}
+
+				return
+			},
+		},
+		"rule_type": {
+			Type:        schema.TypeString,
+			Description: "Direction of the traffic flow to allow, must be INBOUND or OUTBOUND",
+			Required:    true,
+			ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+				validtypes := []string{"INBOUND", "OUTBOUND"}
+				value := v.(string)
+
+				if !contains(value, validtypes) {
+					errors = append(errors, fmt.Errorf("Rule type %q must be one of: %s", k, strings.Join(validtypes, ",")))
+				}
+
+				return
+			},
+		},
+		"ip": {
+			Type:        schema.TypeString,
+			Description: "IP (or starting IP if used with 'size') to apply the rule to",
+			Optional:    true,
+			Computed:    true,
+		},
+		"size": {
+			Type:        schema.TypeString,
+			Description: "Number of IPs to apply the rule from, starting with 'ip'",
+			Optional:    true,
+			Computed:    true,
+		},
+		"range": {
+			Type:        schema.TypeString,
+			Description: "Comma separated list of ports and port ranges",
+			Optional:    true,
+			Computed:    true,
+		},
+		"icmp_type": {
+			Type:        schema.TypeString,
+			Description: "Type of ICMP traffic to apply to when 'protocol' is ICMP",
+			Optional:    true,
+			Computed:    true,
+		},
+		"network_id": {
+			Type:        schema.TypeString,
+			Description: "VNET ID to be used as the source/destination IP addresses",
+			Optional:    true,
+			Computed:    true,
+		},
+		"source_security_group_id": {
+			Type:        schema.TypeString,
+			Description: "Security group ID whose virtual machines IPs are used as the source/destination IP addresses",
+			Optional:    true,
+		},
+	}
+}
+
 func getSecurityGroupController(d *schema.ResourceData, meta interface{}) (*goca.SecurityGroupController, error) {
 	config := meta.(*Configuration)
 	controller := config.Controller
@@ -250,11 +287,25 @@ func resourceOpennebulaSecurityGroupRead(ctx context.Context, d *schema.Resource
 	d.Set("uname", securitygroup.UName)
 	d.Set("gname", securitygroup.GName)
 	d.Set("permissions", permissionsUnixString(*securitygroup.Permissions))
+	d.Set("updated_vms", securitygroup.UpdatedVMs.ID)
+	d.Set("outdated_vms", securitygroup.OutdatedVMs.ID)
+	d.Set("updating_vms", securitygroup.UpdatingVMs.ID)
+	d.Set("error_vms", securitygroup.ErrorVMs.ID)
 
 	description, _ := securitygroup.Template.Get(sgk.Description)
 	d.Set("description", description)
 
-	if err := d.Set("rule", generateSecurityGroupMapFromStructs(securitygroup.Template.GetRules())); err != nil {
+	rules, err := flattenSecurityGroupRules(meta.(*Configuration).Controller, securitygroup.Template.GetRules())
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to flatten rules",
+			Detail:   fmt.Sprintf("security group (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	if err := d.Set("rule", rules); err != nil {
 		log.Printf("[WARN] Error setting rule for Security Group %x, error: %s", securitygroup.ID, err)
 	}
 
@@ -336,6 +387,17 @@ func resourceOpennebulaSecurityGroupCreate(ctx context.Context, d *schema.Resour
 		return diags
 	}
 
+	// rules are generated first as a source security group may not be usable
+	secGroupTpl, err := generateSecurityGroupTemplate(d, meta)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to generate rules",
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
 	secGroupID, err := controller.SecurityGroups().Create(secGroupDef)
 	if err != nil {
 		diags = append(diags, diag.Diagnostic{
@@ -348,8 +410,6 @@ func resourceOpennebulaSecurityGroupCreate(ctx context.Context, d *schema.Resour
 
 	sgc := controller.SecurityGroup(secGroupID)
 
-	secGroupTpl := generateSecurityGroupTemplate(d, meta)
-
 	// add template information into Security group
 	err = sgc.Update(secGroupTpl, 1)
 	if err != nil {
@@ -394,7 +454,7 @@ func resourceOpennebulaSecurityGroupCreate(ctx context.Context, d *schema.Resour
 func resourceOpennebulaSecurityGroupUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 
 	var diags diag.Diagnostics
-	//config := meta.(*Configuration)
+	config := meta.(*Configuration)
 
 	//Get Security Group
 	sgc, err := getSecurityGroupController(d, meta)
@@ -406,6 +466,11 @@ func resourceOpennebulaSecurityGroupUpdate(ctx context.Context, d *schema.Resour
 		})
 		return diags
 	}
+
+	// the template may be updated meanwhile by opennebula_security_group_rule resources
+	rulesKey := securityGroupRulesKey(sgc.ID)
+	config.mutex.Lock(rulesKey)
+	defer config.mutex.Unlock(rulesKey)
 	// TODO: fix it after 5.10 release
 	// Force the "decrypt" bool to false to keep ONE 5.8 behavior
 	securitygroup, err := sgc.Info(false)
@@ -424,7 +489,15 @@ func resourceOpennebulaSecurityGroupUpdate(ctx context.Context, d *schema.Resour
 	if d.HasChange("rule") && d.Get("rule") != "" {
 
 		tpl.Del((string(sgk.RuleVec)))
-		generateSecurityGroupRules(d, tpl)
+		err = generateSecurityGroupRules(d, meta, tpl)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to generate rules",
+				Detail:   fmt.Sprintf("security group (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
 		rulesUpdate = true
 	}
 
@@ -520,6 +593,20 @@ func resourceOpennebulaSecurityGroupUpdate(ctx context.Context, d *schema.Resour
 		}
 
 		log.Printf("[INFO] Successfully commited Security Group %s changes to outdated Virtual Machines\n", securitygroup.Name)
+
+		if d.Get("wait_for_commit").(bool) {
+			_, err = waitForSecurityGroupCommit(ctx, sgc, d.Timeout(schema.TimeoutUpdate))
+			if err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  "Failed to wait for the rules commit",
+					Detail:   fmt.Sprintf("security group (ID: %s): %s", d.Id(), err),
+				})
+				return diags
+			}
+
+			log.Printf("[INFO] Security Group %s changes were propagated to all the Virtual Machines\n", securitygroup.Name)
+		}
 	}
 
 	if d.HasChange("name") {
@@ -606,46 +693,53 @@ func generateSecurityGroup(d *schema.ResourceData) (string, error) {
 	return tplStr, nil
 }
 
-func generateSecurityGroupRules(d *schema.ResourceData, tpl *securitygroup.Template) {
+func generateSecurityGroupRules(d *schema.ResourceData, meta interface{}, tpl *securitygroup.Template) error {
+	config := meta.(*Configuration)
+
 	//Generate rules definition
 	rules := d.Get("rule").([]interface{})
 	log.Printf("Number of Security Group rules: %d", len(rules))
 
 	for i := 0; i < len(rules); i++ {
-		ruleconfig := rules[i].(map[string]interface{})
-		rule := tpl.AddRule()
+		ruleconfig := make(map[string]string)
 
-		for k, v := range ruleconfig {
+		for k, v := range rules[i].(map[string]interface{}) {
 
 			if isEmptyValue(reflect.ValueOf(v)) {
 				continue
 			}
+			ruleconfig[k] = v.(string)
+		}
+
+		// a rule with a source security group may be expanded in several rules
+		expandedRules, err := expandSecurityGroupRule(config.Controller, ruleconfig)
+		if err != nil {
+			return fmt.Errorf("rule %d: %s", i, err)
+		}
+
+		for _, expanded := range expandedRules {
+			rule := tpl.AddRule()
 
-			switch k {
-			case "protocol":
-				rule.Add(sgk.Protocol, v.(string))
-			case "rule_type":
-				rule.Add(sgk.RuleType, v.(string))
-			case "ip":
-				rule.Add(sgk.IP, v.(string))
-			case "size":
-				rule.Add(sgk.Size, v.(string))
-			case "range":
-				rule.Add(sgk.Range, v.(string))
-			case "icmp_type":
-				rule.Add(sgk.IcmpType, v.(string))
-			case "network_id":
-				rule.Add(sgk.NetworkID, v.(string))
+			for k, v := range expanded {
+				key, ok := securityGroupRuleKeys[k]
+				if !ok {
+					continue
+				}
+				rule.Add(key, v)
 			}
 		}
 	}
 
+	return nil
 }
 
-func generateSecurityGroupTemplate(d *schema.ResourceData, meta interface{}) string {
+func generateSecurityGroupTemplate(d *schema.ResourceData, meta interface{}) (string, error) {
 	tpl := securitygroup.NewTemplate()
 
-	generateSecurityGroupRules(d, tpl)
+	err := generateSecurityGroupRules(d, meta, tpl)
+	if err != nil {
+		return "", err
+	}
 
 	description := d.Get("description").(string)
 	if len(description) > 0 {
@@ -679,6 +773,6 @@ func generateSecurityGroupTemplate(d *schema.ResourceData, meta interface{}) str
 	tplStr := tpl.String()
 	log.Printf("[INFO] Security Group template: %s", tplStr)
 
-	return tplStr
+	return tplStr, nil
 
 }
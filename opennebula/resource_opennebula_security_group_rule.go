@@ -0,0 +1,397 @@
+package opennebula
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/parameters"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/schemas/securitygroup"
+	sgk "github.com/OpenNebula/one/src/oca/go/src/goca/schemas/securitygroup/keys"
+)
+
+func resourceOpennebulaSecurityGroupRule() *schema.Resource {
+
+	// a rule can't be modified, only replaced
+	ruleFields := securityGroupRuleFields()
+	for _, field := range ruleFields {
+		field.ForceNew = true
+	}
+
+	return &schema.Resource{
+		CreateContext: resourceOpennebulaSecurityGroupRuleCreate,
+		ReadContext:   resourceOpennebulaSecurityGroupRuleRead,
+		UpdateContext: resourceOpennebulaSecurityGroupRuleUpdate,
+		DeleteContext: resourceOpennebulaSecurityGroupRuleDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceOpennebulaSecurityGroupRuleImport,
+		},
+		CustomizeDiff: resourceSecurityGroupRuleCustomizeDiff,
+		Schema: mergeSchemas(map[string]*schema.Schema{
+			"security_group_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the security group",
+			},
+			"commit": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Should the rule addition and removal be commited to running Virtual Machines?",
+			},
+		}, ruleFields),
+	}
+}
+
+// securityGroupRuleKeys maps the rule attributes to the rule vector keys
+var securityGroupRuleKeys = map[string]sgk.Rule{
+	"protocol":   sgk.Protocol,
+	"rule_type":  sgk.RuleType,
+	"ip":         sgk.IP,
+	"size":       sgk.Size,
+	"range":      sgk.Range,
+	"icmp_type":  sgk.IcmpType,
+	"network_id": sgk.NetworkID,
+
+	"source_security_group_id": sgk.Rule(securityGroupSourceKey),
+}
+
+func securityGroupRulesKey(secGroupID int) *SubResourceKey {
+	return &SubResourceKey{
+		Type:    "secgroup",
+		ID:      secGroupID,
+		SubType: "rules",
+	}
+}
+
+// securityGroupRuleHash identifies a rule by its non empty attributes,
+// regardless of their order in the rule vector. The rules expanded from
+// a source security group share the hash of the configured rule.
+func securityGroupRuleHash(rule map[string]string) string {
+	expanded := false
+	for k, v := range rule {
+		if strings.ToLower(k) == "source_security_group_id" && len(v) > 0 {
+			expanded = true
+		}
+	}
+
+	attrs := make([]string, 0, len(rule))
+	for k, v := range rule {
+		if len(v) == 0 {
+			continue
+		}
+		if expanded && contains(strings.ToLower(k), []string{"ip", "size"}) {
+			continue
+		}
+		attrs = append(attrs, fmt.Sprintf("%s=%s", strings.ToLower(k), v))
+	}
+	sort.Strings(attrs)
+
+	return strconv.Itoa(schema.HashString(strings.Join(attrs, ",")))
+}
+
+// securityGroupRuleFromVector returns the known attributes of a rule vector
+func securityGroupRuleFromVector(ruleVec *securitygroup.Rule) map[string]string {
+	rule := make(map[string]string)
+	for _, pair := range ruleVec.Pairs {
+		attr := strings.ToLower(pair.Key())
+		if _, ok := securityGroupRuleKeys[attr]; !ok {
+			continue
+		}
+		rule[attr] = pair.Value
+	}
+	return rule
+}
+
+func securityGroupRuleFromConfig(d *schema.ResourceData) map[string]string {
+	rule := make(map[string]string)
+	for attr := range securityGroupRuleKeys {
+		rule[attr] = d.Get(attr).(string)
+	}
+	return rule
+}
+
+func parseSecurityGroupRuleID(id string) (int, string, error) {
+	parts := strings.Split(id, ":")
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return 0, "", fmt.Errorf("Invalid ID format, expected <security group ID>:<rule hash>, got %s", id)
+	}
+
+	secGroupID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", fmt.Errorf("Invalid security group ID %q: %s", parts[0], err)
+	}
+
+	return secGroupID, parts[1], nil
+}
+
+// findSecurityGroupRules returns the indexes of the rules matching the hash in the rules list
+func findSecurityGroupRules(rules []securitygroup.Rule, hash string) []int {
+	indexes := make([]int, 0)
+	for i := range rules {
+		if securityGroupRuleHash(securityGroupRuleFromVector(&rules[i])) == hash {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// updateSecurityGroupRules writes the rules of the security group and commits them if asked
+func updateSecurityGroupRules(sgc *goca.SecurityGroupController, tpl *securitygroup.Template, commit bool) error {
+
+	err := sgc.Update(tpl.String(), parameters.Replace)
+	if err != nil {
+		return err
+	}
+
+	if commit {
+		// Only update outdated VMs not all
+		return sgc.Commit(true)
+	}
+
+	return nil
+}
+
+// removeSecurityGroupRules removes the rules at the given indexes from the template
+func removeSecurityGroupRules(tpl *securitygroup.Template, indexes []int) {
+
+	rules := tpl.GetRules()
+
+	removed := make(map[int]bool, len(indexes))
+	for _, i := range indexes {
+		removed[i] = true
+	}
+
+	tpl.Del(string(sgk.RuleVec))
+	for i := range rules {
+		if removed[i] {
+			continue
+		}
+		rule := tpl.AddRule()
+		for _, pair := range rules[i].Pairs {
+			rule.AddPair(pair.Key(), pair.Value)
+		}
+	}
+}
+
+func resourceOpennebulaSecurityGroupRuleCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	secGroupID := d.Get("security_group_id").(int)
+
+	// the rules are written all together in the security group template
+	rulesKey := securityGroupRulesKey(secGroupID)
+	config.mutex.Lock(rulesKey)
+	defer config.mutex.Unlock(rulesKey)
+
+	sgc := controller.SecurityGroup(secGroupID)
+
+	secGroup, err := sgc.Info(false)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("security group (ID: %d): %s", secGroupID, err),
+		})
+		return diags
+	}
+
+	ruleConfig := securityGroupRuleFromConfig(d)
+	hash := securityGroupRuleHash(ruleConfig)
+
+	if len(findSecurityGroupRules(secGroup.Template.GetRules(), hash)) > 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "The rule already exists",
+			Detail:   fmt.Sprintf("security group (ID: %d): the rule %s already exists, import it instead", secGroupID, hash),
+		})
+		return diags
+	}
+
+	rulesConfig, err := expandSecurityGroupRule(controller, ruleConfig)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to expand the rule",
+			Detail:   fmt.Sprintf("security group (ID: %d): %s", secGroupID, err),
+		})
+		return diags
+	}
+
+	for _, ruleConfig := range rulesConfig {
+		rule := secGroup.Template.AddRule()
+		for attr, key := range securityGroupRuleKeys {
+			if len(ruleConfig[attr]) == 0 {
+				continue
+			}
+			rule.Add(key, ruleConfig[attr])
+		}
+	}
+
+	err = updateSecurityGroupRules(sgc, &secGroup.Template, d.Get("commit").(bool))
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to add the rule",
+			Detail:   fmt.Sprintf("security group (ID: %d): %s", secGroupID, err),
+		})
+		return diags
+	}
+
+	d.SetId(fmt.Sprintf("%d:%s", secGroupID, hash))
+
+	log.Printf("[INFO] Successfully added rule %s to security group %d\n", hash, secGroupID)
+
+	return resourceOpennebulaSecurityGroupRuleRead(ctx, d, meta)
+}
+
+func resourceOpennebulaSecurityGroupRuleRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	secGroupID, hash, err := parseSecurityGroupRuleID(d.Id())
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to parse the security group rule ID",
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
+	secGroup, err := controller.SecurityGroup(secGroupID).Info(false)
+	if err != nil {
+		if NoExists(err) {
+			log.Printf("[WARN] Removing security group rule %s from state because the security group no longer exists", d.Id())
+			d.SetId("")
+			return nil
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("security group (ID: %d): %s", secGroupID, err),
+		})
+		return diags
+	}
+
+	rules := secGroup.Template.GetRules()
+	indexes := findSecurityGroupRules(rules, hash)
+	if len(indexes) == 0 {
+		log.Printf("[WARN] Removing security group rule %s from state because it no longer exists", d.Id())
+		d.SetId("")
+		return nil
+	}
+
+	ruleVectors := make([]securitygroup.Rule, 0, len(indexes))
+	for _, i := range indexes {
+		ruleVectors = append(ruleVectors, rules[i])
+	}
+
+	// the expanded rules are merged back
+	flattenedRules, err := flattenSecurityGroupRules(controller, ruleVectors)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to flatten the rule",
+			Detail:   fmt.Sprintf("security group (ID: %d): %s", secGroupID, err),
+		})
+		return diags
+	}
+
+	d.Set("security_group_id", secGroupID)
+
+	rule := flattenedRules[0]
+	for attr := range securityGroupRuleKeys {
+		value, _ := rule[attr].(string)
+		d.Set(attr, value)
+	}
+
+	return nil
+}
+
+// resourceOpennebulaSecurityGroupRuleUpdate only handles the commit flag which applies to the next changes
+func resourceOpennebulaSecurityGroupRuleUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return resourceOpennebulaSecurityGroupRuleRead(ctx, d, meta)
+}
+
+func resourceOpennebulaSecurityGroupRuleDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	secGroupID, hash, err := parseSecurityGroupRuleID(d.Id())
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to parse the security group rule ID",
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
+	rulesKey := securityGroupRulesKey(secGroupID)
+	config.mutex.Lock(rulesKey)
+	defer config.mutex.Unlock(rulesKey)
+
+	sgc := controller.SecurityGroup(secGroupID)
+
+	secGroup, err := sgc.Info(false)
+	if err != nil {
+		if NoExists(err) {
+			return nil
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("security group (ID: %d): %s", secGroupID, err),
+		})
+		return diags
+	}
+
+	indexes := findSecurityGroupRules(secGroup.Template.GetRules(), hash)
+	if len(indexes) == 0 {
+		log.Printf("[WARN] Security group rule %s already removed", d.Id())
+		return nil
+	}
+	removeSecurityGroupRules(&secGroup.Template, indexes)
+
+	err = updateSecurityGroupRules(sgc, &secGroup.Template, d.Get("commit").(bool))
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to remove the rule",
+			Detail:   fmt.Sprintf("security group (ID: %d): %s", secGroupID, err),
+		})
+		return diags
+	}
+
+	log.Printf("[INFO] Successfully removed rule %s from security group %d\n", hash, secGroupID)
+
+	return nil
+}
+
+func resourceOpennebulaSecurityGroupRuleImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+
+	_, _, err := parseSecurityGroupRuleID(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	d.Set("commit", true)
+
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,181 @@
+package opennebula
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestSecurityGroupRuleHash(t *testing.T) {
+	hash := securityGroupRuleHash(map[string]string{
+		"protocol":  "TCP",
+		"rule_type": "INBOUND",
+		"range":     "22",
+		"ip":        "",
+	})
+
+	// the empty attributes and the keys case are ignored
+	sameHash := securityGroupRuleHash(map[string]string{
+		"RANGE":     "22",
+		"RULE_TYPE": "INBOUND",
+		"PROTOCOL":  "TCP",
+	})
+	if hash != sameHash {
+		t.Fatalf("expected the same hash, got %s and %s", hash, sameHash)
+	}
+
+	otherHash := securityGroupRuleHash(map[string]string{
+		"protocol":  "TCP",
+		"rule_type": "INBOUND",
+		"range":     "80",
+	})
+	if hash == otherHash {
+		t.Fatalf("expected different hashes, got %s", hash)
+	}
+}
+
+func TestSecurityGroupRuleHashExpanded(t *testing.T) {
+	hash := securityGroupRuleHash(map[string]string{
+		"protocol":                 "TCP",
+		"rule_type":                "INBOUND",
+		"source_security_group_id": "100",
+	})
+
+	// the rules expanded from a source security group share the hash of the configured rule
+	expandedHash := securityGroupRuleHash(map[string]string{
+		"PROTOCOL":                 "TCP",
+		"RULE_TYPE":                "INBOUND",
+		"SOURCE_SECURITY_GROUP_ID": "100",
+		"IP":                       "10.0.0.1",
+		"SIZE":                     "1",
+	})
+	if hash != expandedHash {
+		t.Fatalf("expected the same hash, got %s and %s", hash, expandedHash)
+	}
+}
+
+func TestValidateSecurityGroupRule(t *testing.T) {
+	valid := []map[string]string{
+		{"protocol": "TCP", "ip": "10.0.0.1", "size": "10"},
+		{"protocol": "TCP", "network_id": "1"},
+		{"protocol": "TCP", "source_security_group_id": "100"},
+	}
+	for _, rule := range valid {
+		err := validateSecurityGroupRule(rule)
+		if err != nil {
+			t.Fatalf("unexpected error for rule %v: %s", rule, err)
+		}
+	}
+
+	invalid := []map[string]string{
+		{"protocol": "TCP", "network_id": "1", "ip": "10.0.0.1"},
+		{"protocol": "TCP", "network_id": "1", "size": "10"},
+		{"protocol": "TCP", "source_security_group_id": "100", "ip": "10.0.0.1"},
+		{"protocol": "TCP", "source_security_group_id": "100", "network_id": "1"},
+	}
+	for _, rule := range invalid {
+		err := validateSecurityGroupRule(rule)
+		if err == nil {
+			t.Fatalf("expected an error for rule %v", rule)
+		}
+	}
+}
+
+func TestParseSecurityGroupRuleID(t *testing.T) {
+	secGroupID, hash, err := parseSecurityGroupRuleID("100:12345")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if secGroupID != 100 || hash != "12345" {
+		t.Fatalf("expected 100:12345, got %d:%s", secGroupID, hash)
+	}
+
+	for _, id := range []string{"100", "100:", "a:12345", "100:1:2"} {
+		_, _, err := parseSecurityGroupRuleID(id)
+		if err == nil {
+			t.Fatalf("expected an error for ID %q", id)
+		}
+	}
+}
+
+func TestAccSecurityGroupRule(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSecurityGroupDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSecurityGroupRuleConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("opennebula_security_group_rule.ssh", "security_group_id", "opennebula_security_group.test", "id"),
+					resource.TestCheckResourceAttr("opennebula_security_group_rule.ssh", "protocol", "TCP"),
+					resource.TestCheckResourceAttr("opennebula_security_group_rule.ssh", "range", "22"),
+					resource.TestCheckResourceAttr("opennebula_security_group_rule.http", "range", "80"),
+				),
+			},
+			{
+				ResourceName:      "opennebula_security_group_rule.ssh",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				// the rules of the standalone resources are read back in the computed rule list of the group
+				Config: testAccSecurityGroupRuleConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_security_group.test", "rule.#", "2"),
+					resource.TestCheckTypeSetElemNestedAttrs("opennebula_security_group.test", "rule.*", map[string]string{
+						"protocol": "TCP",
+						"range":    "22",
+					}),
+					resource.TestCheckTypeSetElemNestedAttrs("opennebula_security_group.test", "rule.*", map[string]string{
+						"protocol": "TCP",
+						"range":    "80",
+					}),
+				),
+			},
+			{
+				Config: testAccSecurityGroupRuleConfig + testAccSecurityGroupRuleEmptySourceConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("opennebula_security_group_rule.peers", "source_security_group_id", "opennebula_security_group.source", "id"),
+					resource.TestCheckResourceAttr("opennebula_security_group_rule.peers", "ip", ""),
+					resource.TestCheckResourceAttr("opennebula_security_group_rule.peers", "size", ""),
+				),
+			},
+		},
+	})
+}
+
+var testAccSecurityGroupRuleConfig = `
+resource "opennebula_security_group" "test" {
+  name        = "test-security-group-rule"
+  description = "Security group with standalone rules"
+}
+
+resource "opennebula_security_group_rule" "ssh" {
+  security_group_id = opennebula_security_group.test.id
+  protocol          = "TCP"
+  rule_type         = "INBOUND"
+  range             = "22"
+}
+
+resource "opennebula_security_group_rule" "http" {
+  security_group_id = opennebula_security_group.test.id
+  protocol          = "TCP"
+  rule_type         = "INBOUND"
+  range             = "80"
+}
+`
+
+// the source security group has no member, the rule must be kept without allowing any traffic
+var testAccSecurityGroupRuleEmptySourceConfig = `
+resource "opennebula_security_group" "source" {
+  name = "test-security-group-rule-source"
+}
+
+resource "opennebula_security_group_rule" "peers" {
+  security_group_id        = opennebula_security_group.test.id
+  protocol                 = "ALL"
+  rule_type                = "INBOUND"
+  source_security_group_id = opennebula_security_group.source.id
+}
+`
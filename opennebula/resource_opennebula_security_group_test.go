@@ -47,6 +47,10 @@ func TestAccSecurityGroup(t *testing.T) {
 					resource.TestCheckResourceAttr("opennebula_security_group.mysecgroup", "tags.env", "dev"),
 					resource.TestCheckResourceAttr("opennebula_security_group.mysecgroup", "tags.customer", "test"),
 					resource.TestCheckResourceAttr("opennebula_security_group.mysecgroup", "tags.version", "2"),
+					resource.TestCheckResourceAttr("opennebula_security_group.mysecgroup", "wait_for_commit", "true"),
+					resource.TestCheckResourceAttr("opennebula_security_group.mysecgroup", "outdated_vms.#", "0"),
+					resource.TestCheckResourceAttr("opennebula_security_group.mysecgroup", "updating_vms.#", "0"),
+					resource.TestCheckResourceAttr("opennebula_security_group.mysecgroup", "error_vms.#", "0"),
 				),
 			},
 		},
@@ -135,6 +139,7 @@ resource "opennebula_security_group" "mysecgroup" {
     name = "renamedsg"
     description = "Terraform security group"
     permissions = "660"
+    wait_for_commit = true
     rule {
         protocol = "ALL"
         rule_type = "OUTBOUND"
@@ -38,6 +38,66 @@ func resourceOpennebulaTemplate() *schema.Resource {
 			map[string]*schema.Schema{
 				"nic_alias": nicAliasSchema(),
 			},
+			map[string]*schema.Schema{
+				"versioning": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					MaxItems:    1,
+					Description: "Publish each change of the template as a new immutable template version",
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"keep": {
+								Type:        schema.TypeInt,
+								Optional:    true,
+								Default:     0,
+								Description: "Number of versions to keep, the oldest ones are deleted. Defaults to 0: keep all versions",
+								ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+									if v.(int) < 0 {
+										errors = append(errors, fmt.Errorf("%q must be greater or equal to 0", k))
+									}
+									return
+								},
+							},
+							"name_suffix": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Default:     "-v",
+								Description: "Suffix added to the template name, followed by the version number, to name the versions",
+							},
+						},
+					},
+				},
+				"latest_id": {
+					Type:        schema.TypeInt,
+					Computed:    true,
+					Description: "ID of the latest template version. -1 when no version was published",
+				},
+				"versions": {
+					Type:        schema.TypeList,
+					Computed:    true,
+					Description: "List of the published template versions",
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"version": {
+								Type:     schema.TypeInt,
+								Computed: true,
+							},
+							"template_id": {
+								Type:     schema.TypeInt,
+								Computed: true,
+							},
+							"name": {
+								Type:     schema.TypeString,
+								Computed: true,
+							},
+							"reg_time": {
+								Type:     schema.TypeInt,
+								Computed: true,
+							},
+						},
+					},
+				},
+			},
 		),
 	}
 }
@@ -71,6 +131,7 @@ func commonTemplateSchemas() map[string]*schema.Schema {
 				Optional:    true,
 				Description: "Provides the template creator with the possibility to dynamically ask the user instantiating the template for dynamic values that must be defined.",
 			},
+			"user_input": userInputSchema(),
 		},
 	)
 }
@@ -246,9 +307,58 @@ func resourceOpennebulaTemplateCreate(ctx context.Context, d *schema.ResourceDat
 		return diags
 	}
 
+	if _, ok := d.GetOk("versioning"); ok {
+		diags = publishTemplateResourceVersion(d, meta)
+		if len(diags) > 0 {
+			return diags
+		}
+	}
+
 	return resourceOpennebulaTemplateRead(ctx, d, meta)
 }
 
+// publishTemplateResourceVersion publishes the current content of the template as a new version
+func publishTemplateResourceVersion(d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	var diags diag.Diagnostics
+
+	tc, err := getTemplateController(d, meta)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to get the template controller",
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
+	tpl, err := tc.Info(false, false)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	versioning := d.Get("versioning").([]interface{})[0].(map[string]interface{})
+
+	_, err = publishTemplateVersion(controller, tpl, versioning["name_suffix"].(string), versioning["keep"].(int))
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to publish template version",
+			Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	return nil
+}
+
 func resourceOpennebulaTemplateCreateCustom(ctx context.Context, d *schema.ResourceData, meta interface{}, customFunc customDynTemplateFunc) diag.Diagnostics {
 	config := meta.(*Configuration)
 	controller := config.Controller
@@ -341,7 +451,24 @@ func resourceOpennebulaTemplateCreateCustom(ctx context.Context, d *schema.Resou
 }
 
 func resourceOpennebulaTemplateRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	return resourceOpennebulaTemplateReadCustom(ctx, d, meta, templateReadCustom)
+	config := meta.(*Configuration)
+
+	diags := resourceOpennebulaTemplateReadCustom(ctx, d, meta, templateReadCustom)
+	if len(diags) > 0 || d.Id() == "" {
+		return diags
+	}
+
+	err := flattenTemplateVersions(d, config.Controller)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to flatten template versions",
+			Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	return nil
 }
 
 func templateReadCustom(ctx context.Context, d *schema.ResourceData, templateInfos *template.Template) diag.Diagnostics {
@@ -423,23 +550,14 @@ func resourceOpennebulaTemplateReadCustom(ctx context.Context, d *schema.Resourc
 		return diags
 	}
 
-	uInputs, _ := tpl.Template.GetVector("USER_INPUTS")
-	if uInputs != nil && len(uInputs.Pairs) > 0 {
-		uInputsMap := make(map[string]interface{}, 0)
-
-		for _, ui := range uInputs.Pairs {
-			uInputsMap[ui.Key()] = ui.Value
-		}
-
-		err = d.Set("user_inputs", uInputsMap)
-		if err != nil {
-			diags = append(diags, diag.Diagnostic{
-				Severity: diag.Error,
-				Summary:  "Failed to set attribute",
-				Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
-			})
-			return diags
-		}
+	err = flattenUserInputs(d, &tpl.Template.Template)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to flatten user inputs",
+			Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+		})
+		return diags
 	}
 
 	if readCustom != nil {
@@ -748,18 +866,17 @@ func resourceOpennebulaTemplateUpdateCustom(ctx context.Context, d *schema.Resou
 		}
 	}
 
-	if d.HasChange("user_inputs") {
+	if d.HasChanges("user_inputs", "user_input") {
 		newTpl.Del("USER_INPUTS")
 
-		uInputs := d.Get("user_inputs").(map[string]interface{})
-		if len(uInputs) > 0 {
-
-			vec := newTpl.AddVector("USER_INPUTS")
-			if len(uInputs) > 0 {
-				for k, v := range uInputs {
-					vec.AddPair(k, v)
-				}
-			}
+		err := addUserInputs(d, &newTpl.Template)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to update user inputs",
+				Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
 		}
 
 		update = true
@@ -892,6 +1009,14 @@ func resourceOpennebulaTemplateUpdate(ctx context.Context, d *schema.ResourceDat
 		return nil
 	}
 
+	// publish a new version only if the template content changed
+	if _, ok := d.GetOk("versioning"); ok && d.HasChangesExcept("versioning", "permissions", "group", "lock") {
+		diags := publishTemplateResourceVersion(d, meta)
+		if len(diags) > 0 {
+			return diags
+		}
+	}
+
 	return resourceOpennebulaTemplateRead(ctx, d, meta)
 }
 
@@ -921,6 +1046,30 @@ func resourceOpennebulaTemplateDelete(ctx context.Context, d *schema.ResourceDat
 
 	log.Printf("[INFO] Successfully deleted Template ID %s\n", d.Id())
 
+	controller := meta.(*Configuration).Controller
+
+	versions, err := getTemplateVersions(controller, tc.ID)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve template versions",
+			Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	for _, v := range versions {
+		err = deleteTemplateVersion(controller, v.ID)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to delete template version",
+				Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
 	return nil
 }
 
@@ -957,16 +1106,12 @@ func generateTemplate(d *schema.ResourceData, meta interface{}) (*vm.Template, e
 		tpl.AddCtx(vmk.Context(keyUp), fmt.Sprint(value))
 	}
 
-	uInputs := d.Get("user_inputs").(map[string]interface{})
-	if len(uInputs) > 0 {
-		vec := tpl.AddVector("USER_INPUTS")
-
-		for k, v := range uInputs {
-			vec.AddPair(k, v)
-		}
+	err := addUserInputs(d, &tpl.Template)
+	if err != nil {
+		return nil, err
 	}
 
-	err := generateVMTemplate(d, tpl)
+	err = generateVMTemplate(d, tpl)
 	if err != nil {
 		return nil, err
 	}
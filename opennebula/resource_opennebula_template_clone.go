@@ -0,0 +1,471 @@
+package opennebula
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca"
+	dyn "github.com/OpenNebula/one/src/oca/go/src/goca/dynamic"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/parameters"
+)
+
+func resourceOpennebulaTemplateClone() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceOpennebulaTemplateCloneCreate,
+		ReadContext:   resourceOpennebulaTemplateCloneRead,
+		UpdateContext: resourceOpennebulaTemplateCloneUpdate,
+		DeleteContext: resourceOpennebulaTemplateCloneDelete,
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(defaultImageTimeout),
+			Delete: schema.DefaultTimeout(defaultImageTimeout),
+		},
+		CustomizeDiff: resourceTemplateCloneCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"template_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the template to clone",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Name of the cloned template",
+			},
+			"recursive": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				ForceNew:    true,
+				Description: "Clone the images of the template disks too",
+			},
+			"datastore_id": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     -1,
+				ForceNew:    true,
+				Description: "ID of the datastore where the images are cloned. Defaults to -1: images are cloned in their original datastore",
+			},
+			"image_ids": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "IDs of the cloned images, when the clone is recursive",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"permissions": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "Permissions for the cloned template (in Unix format, owner-group-other, use-manage-admin)",
+			},
+			"group": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Name of the Group that onws the cloned template, If empty, it uses caller group",
+			},
+			"uid": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "ID of the user that will own the cloned template",
+			},
+			"gid": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "ID of the group that will own the cloned template",
+			},
+			"uname": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Name of the user that will own the cloned template",
+			},
+			"gname": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Name of the group that will own the cloned template",
+			},
+			"reg_time": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Registration time",
+			},
+		},
+	}
+}
+
+// getTemplateDiskVectors returns the DISK vectors of the template, in the template order
+func getTemplateDiskVectors(tpl *dyn.Template) []*dyn.Vector {
+	disks := make([]*dyn.Vector, 0)
+	for _, e := range tpl.Elements {
+		vec, ok := e.(*dyn.Vector)
+		if !ok || vec.Key() != "DISK" {
+			continue
+		}
+		disks = append(disks, vec)
+	}
+	return disks
+}
+
+// cloneTemplateImages clones the images of the template disks into the datastore and update the disks
+// to use the cloned images. It returns the IDs of the cloned images.
+func cloneTemplateImages(controller *goca.Controller, name string, tpl *dyn.Template, datastoreID int) ([]int, error) {
+
+	imageIDs := make([]int, 0)
+
+	for i, disk := range getTemplateDiskVectors(tpl) {
+
+		imageID, err := disk.GetInt("IMAGE_ID")
+		if err != nil {
+			imageName, err := disk.GetStr("IMAGE")
+			if err != nil {
+				// volatile disk
+				continue
+			}
+			imageID, err = controller.Images().ByName(imageName)
+			if err != nil {
+				return imageIDs, fmt.Errorf("can't find image %s: %s", imageName, err)
+			}
+		}
+
+		cloneID, err := controller.Image(imageID).Clone(fmt.Sprintf("%s-disk-%d", name, i), datastoreID)
+		if err != nil {
+			return imageIDs, fmt.Errorf("can't clone image %d: %s", imageID, err)
+		}
+		imageIDs = append(imageIDs, cloneID)
+
+		disk.Del("IMAGE_ID")
+		disk.Del("IMAGE")
+		disk.Del("IMAGE_UNAME")
+		disk.Del("IMAGE_UID")
+		disk.AddPair("IMAGE_ID", cloneID)
+	}
+
+	return imageIDs, nil
+}
+
+// resourceTemplateCloneCustomizeDiff rejects a target datastore when the images aren't cloned
+func resourceTemplateCloneCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	if !diff.NewValueKnown("datastore_id") || !diff.NewValueKnown("recursive") {
+		return nil
+	}
+
+	if diff.Get("datastore_id").(int) != -1 && !diff.Get("recursive").(bool) {
+		return fmt.Errorf("datastore_id requires recursive to be set, only the cloned images are stored in it")
+	}
+
+	return nil
+}
+
+func resourceOpennebulaTemplateCloneCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	var diags diag.Diagnostics
+
+	templateID := d.Get("template_id").(int)
+	name := d.Get("name").(string)
+	recursive := d.Get("recursive").(bool)
+	datastoreID := d.Get("datastore_id").(int)
+
+	tc := controller.Template(templateID)
+
+	var cloneID int
+	var err error
+	imageIDs := make([]int, 0)
+
+	if recursive && datastoreID != -1 {
+
+		// OpenNebula clones the images in their original datastore, so the images are cloned first
+		// then the template disks are updated to use them
+		tpl, err := tc.Info(false, false)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to retrieve informations",
+				Detail:   fmt.Sprintf("template (ID: %d): %s", templateID, err),
+			})
+			return diags
+		}
+
+		imageIDs, err = cloneTemplateImages(controller, name, &tpl.Template.Template, datastoreID)
+		if err != nil {
+			for _, imageID := range imageIDs {
+				controller.Image(imageID).Delete()
+			}
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to clone the template images",
+				Detail:   fmt.Sprintf("template (ID: %d): %s", templateID, err),
+			})
+			return diags
+		}
+
+		cloneID, err = tc.Clone(name, false)
+		if err != nil {
+			for _, imageID := range imageIDs {
+				controller.Image(imageID).Delete()
+			}
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to clone the template",
+				Detail:   fmt.Sprintf("template (ID: %d): %s", templateID, err),
+			})
+			return diags
+		}
+
+		d.SetId(fmt.Sprintf("%v", cloneID))
+		d.Set("image_ids", imageIDs)
+
+		tpl.Template.Del("NAME")
+		err = controller.Template(cloneID).Update(tpl.Template.String(), parameters.Replace)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to update the template disks",
+				Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	} else {
+
+		cloneID, err = tc.Clone(name, recursive)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to clone the template",
+				Detail:   fmt.Sprintf("template (ID: %d): %s", templateID, err),
+			})
+			return diags
+		}
+
+		d.SetId(fmt.Sprintf("%v", cloneID))
+
+		if recursive {
+			tpl, err := controller.Template(cloneID).Info(false, false)
+			if err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  "Failed to retrieve informations",
+					Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+				})
+				return diags
+			}
+
+			for _, disk := range getTemplateDiskVectors(&tpl.Template.Template) {
+				imageID, err := disk.GetInt("IMAGE_ID")
+				if err != nil {
+					continue
+				}
+				imageIDs = append(imageIDs, imageID)
+			}
+
+			d.Set("image_ids", imageIDs)
+		}
+	}
+
+	log.Printf("[INFO] Template %d cloned (ID: %d), with images: %v", templateID, cloneID, imageIDs)
+
+	timeout := d.Timeout(schema.TimeoutCreate)
+	for _, imageID := range imageIDs {
+		_, err = waitForImageState(ctx, controller.Image(imageID), timeout, "READY")
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to wait image to be in READY state",
+				Detail:   fmt.Sprintf("template (ID: %s): image (ID: %d): %s", d.Id(), imageID, err),
+			})
+			return diags
+		}
+	}
+
+	ctc := controller.Template(cloneID)
+
+	if perms, ok := d.GetOk("permissions"); ok {
+		err = ctc.Chmod(permissionUnix(perms.(string)))
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to change permissions",
+				Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
+	if d.Get("group") != "" {
+		err = changeTemplateGroup(d, meta)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to change group",
+				Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
+	return resourceOpennebulaTemplateCloneRead(ctx, d, meta)
+}
+
+func resourceOpennebulaTemplateCloneRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	var diags diag.Diagnostics
+
+	tc, err := getTemplateController(d, meta)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to get the template controller",
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
+	tpl, err := tc.Info(false, false)
+	if err != nil {
+		if NoExists(err) {
+			log.Printf("[WARN] Removing template clone %s from state because it no longer exists in", d.Get("name"))
+			d.SetId("")
+			return nil
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	d.Set("name", tpl.Name)
+	d.Set("uid", tpl.UID)
+	d.Set("gid", tpl.GID)
+	d.Set("uname", tpl.UName)
+	d.Set("gname", tpl.GName)
+	d.Set("reg_time", tpl.RegTime)
+	d.Set("permissions", permissionsUnixString(*tpl.Permissions))
+
+	return nil
+}
+
+func resourceOpennebulaTemplateCloneUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	var diags diag.Diagnostics
+
+	tc, err := getTemplateController(d, meta)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to get the template controller",
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
+	if d.HasChange("name") {
+		err := tc.Rename(d.Get("name").(string))
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to rename",
+				Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
+	if d.HasChange("permissions") {
+		if perms, ok := d.GetOk("permissions"); ok {
+			err = tc.Chmod(permissionUnix(perms.(string)))
+			if err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  "Failed to change permissions",
+					Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+				})
+				return diags
+			}
+		}
+	}
+
+	if d.HasChange("group") {
+		err = changeTemplateGroup(d, meta)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to change group",
+				Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
+	return resourceOpennebulaTemplateCloneRead(ctx, d, meta)
+}
+
+func resourceOpennebulaTemplateCloneDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	var diags diag.Diagnostics
+
+	tc, err := getTemplateController(d, meta)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to get the template controller",
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
+	err = tc.Delete()
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to delete",
+			Detail:   fmt.Sprintf("template (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	log.Printf("[INFO] Successfully deleted template clone ID %s\n", d.Id())
+
+	timeout := d.Timeout(schema.TimeoutDelete)
+	for _, imageIDIf := range d.Get("image_ids").([]interface{}) {
+		imageID := imageIDIf.(int)
+		ic := controller.Image(imageID)
+
+		err = ic.Delete()
+		if err != nil {
+			if NoExists(err) {
+				continue
+			}
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to delete the cloned image",
+				Detail:   fmt.Sprintf("template (ID: %s): image (ID: %d): %s", d.Id(), imageID, err),
+			})
+			return diags
+		}
+
+		_, err = waitForImageState(ctx, ic, timeout, "notfound")
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to wait image to be in NOTFOUND state",
+				Detail:   fmt.Sprintf("template (ID: %s): image (ID: %s): %s", d.Id(), strconv.Itoa(imageID), err),
+			})
+			return diags
+		}
+	}
+
+	return nil
+}
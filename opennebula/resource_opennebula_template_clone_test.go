@@ -0,0 +1,162 @@
+package opennebula
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccTemplateClone(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckTemplateCloneDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccTemplateCloneDatastoreNotRecursive,
+				ExpectError: regexp.MustCompile("datastore_id requires recursive to be set"),
+			},
+			{
+				Config: testAccTemplateCloneRecursive,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_template_clone.clone", "name", "terra-tpl-clone"),
+					resource.TestCheckResourceAttr("opennebula_template_clone.clone", "recursive", "true"),
+					resource.TestCheckResourceAttr("opennebula_template_clone.clone", "datastore_id", "1"),
+					resource.TestCheckResourceAttr("opennebula_template_clone.clone", "image_ids.#", "1"),
+					resource.TestCheckResourceAttr("opennebula_template_clone.clone", "permissions", "660"),
+					resource.TestCheckResourceAttrSet("opennebula_template_clone.clone", "uid"),
+					resource.TestCheckResourceAttrSet("opennebula_template_clone.clone", "gid"),
+					testAccCheckTemplateCloneImages("opennebula_template_clone.clone"),
+				),
+			},
+			{
+				Config: testAccTemplateCloneRenamed,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_template_clone.clone", "name", "terra-tpl-clone-renamed"),
+					resource.TestCheckResourceAttr("opennebula_template_clone.clone", "image_ids.#", "1"),
+				),
+			},
+		},
+	})
+}
+
+// testAccCheckTemplateCloneImages checks that the cloned template disks use the cloned images
+func testAccCheckTemplateCloneImages(resourceName string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		controller := testAccProvider.Meta().(*Configuration).Controller
+
+		rs, ok := s.RootModule().Resources[resourceName]
+		if !ok {
+			return fmt.Errorf("resource %s not found", resourceName)
+		}
+
+		templateID, _ := strconv.ParseUint(rs.Primary.ID, 10, 0)
+		tpl, err := controller.Template(int(templateID)).Info(false, false)
+		if err != nil {
+			return err
+		}
+
+		disks := getTemplateDiskVectors(&tpl.Template.Template)
+		if len(disks) != 1 {
+			return fmt.Errorf("expected 1 disk, got %d", len(disks))
+		}
+
+		imageID, err := disks[0].GetInt("IMAGE_ID")
+		if err != nil {
+			return err
+		}
+		if strconv.Itoa(imageID) != rs.Primary.Attributes["image_ids.0"] {
+			return fmt.Errorf("expected disk image %s, got %d", rs.Primary.Attributes["image_ids.0"], imageID)
+		}
+
+		img, err := controller.Image(imageID).Info(false)
+		if err != nil {
+			return err
+		}
+		if img.DatastoreID != 1 {
+			return fmt.Errorf("expected image %d in datastore 1, got %d", imageID, img.DatastoreID)
+		}
+
+		return nil
+	}
+}
+
+func testAccCheckTemplateCloneDestroy(s *terraform.State) error {
+	controller := testAccProvider.Meta().(*Configuration).Controller
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "opennebula_template_clone" {
+			continue
+		}
+
+		templateID, _ := strconv.ParseUint(rs.Primary.ID, 10, 0)
+		template, _ := controller.Template(int(templateID)).Info(false, false)
+		if template != nil {
+			return fmt.Errorf("Expected template %s to have been destroyed", rs.Primary.ID)
+		}
+
+		imageID, _ := strconv.ParseUint(rs.Primary.Attributes["image_ids.0"], 10, 0)
+		image, _ := controller.Image(int(imageID)).Info(false)
+		if image != nil {
+			return fmt.Errorf("Expected image %d to have been destroyed", imageID)
+		}
+	}
+
+	return nil
+}
+
+var testAccTemplateCloneSource = `
+resource "opennebula_image" "image" {
+  name         = "terra-tpl-clone-image"
+  datastore_id = 1
+  persistent   = false
+  type         = "DATABLOCK"
+  size         = "16"
+  dev_prefix   = "vd"
+  driver       = "qcow2"
+}
+
+resource "opennebula_template" "template" {
+  name   = "terra-tpl-clone-source"
+  cpu    = 0.5
+  vcpu   = 1
+  memory = 128
+
+  disk {
+    image_id = opennebula_image.image.id
+    target   = "vda"
+  }
+}
+`
+
+var testAccTemplateCloneDatastoreNotRecursive = testAccTemplateCloneSource + `
+resource "opennebula_template_clone" "clone" {
+  template_id  = opennebula_template.template.id
+  name         = "terra-tpl-clone"
+  datastore_id = 1
+}
+`
+
+var testAccTemplateCloneRecursive = testAccTemplateCloneSource + `
+resource "opennebula_template_clone" "clone" {
+  template_id  = opennebula_template.template.id
+  name         = "terra-tpl-clone"
+  recursive    = true
+  datastore_id = 1
+  permissions  = "660"
+}
+`
+
+var testAccTemplateCloneRenamed = testAccTemplateCloneSource + `
+resource "opennebula_template_clone" "clone" {
+  template_id  = opennebula_template.template.id
+  name         = "terra-tpl-clone-renamed"
+  recursive    = true
+  datastore_id = 1
+  permissions  = "660"
+}
+`
@@ -3,6 +3,7 @@ package opennebula
 import (
 	"fmt"
 	"reflect"
+	"regexp"
 	"strconv"
 	"testing"
 
@@ -201,6 +202,124 @@ func TestAccTemplate(t *testing.T) {
 	})
 }
 
+func TestAccTemplateVersioning(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckTemplateDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccTemplateVersioning, 128),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_template.template", "name", "terra-tpl-versioning"),
+					resource.TestCheckResourceAttr("opennebula_template.template", "versions.#", "1"),
+					resource.TestCheckResourceAttr("opennebula_template.template", "versions.0.version", "1"),
+					resource.TestCheckResourceAttr("opennebula_template.template", "versions.0.name", "terra-tpl-versioning-v1"),
+					resource.TestCheckResourceAttrPair("opennebula_template.template", "latest_id", "opennebula_template.template", "versions.0.template_id"),
+				),
+			},
+			{
+				Config: fmt.Sprintf(testAccTemplateVersioning, 256),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_template.template", "memory", "256"),
+					resource.TestCheckResourceAttr("opennebula_template.template", "versions.#", "2"),
+					resource.TestCheckResourceAttr("opennebula_template.template", "versions.1.version", "2"),
+					resource.TestCheckResourceAttrPair("opennebula_template.template", "latest_id", "opennebula_template.template", "versions.1.template_id"),
+				),
+			},
+			{
+				Config: fmt.Sprintf(testAccTemplateVersioning, 512),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_template.template", "memory", "512"),
+					resource.TestCheckResourceAttr("opennebula_template.template", "versions.#", "2"),
+					resource.TestCheckResourceAttr("opennebula_template.template", "versions.0.version", "2"),
+					resource.TestCheckResourceAttr("opennebula_template.template", "versions.1.version", "3"),
+					resource.TestCheckResourceAttr("opennebula_template.template", "versions.1.name", "terra-tpl-versioning-v3"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccTemplateUserInput(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckTemplateDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccTemplateUserInput,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_template.template", "user_input.#", "3"),
+					resource.TestCheckTypeSetElemNestedAttrs("opennebula_template.template", "user_input.*", map[string]string{
+						"name":        "BLOG_TITLE",
+						"type":        "text",
+						"mandatory":   "true",
+						"description": "Blog Title",
+					}),
+					resource.TestCheckTypeSetElemNestedAttrs("opennebula_template.template", "user_input.*", map[string]string{
+						"name":      "DISK_SIZE",
+						"type":      "range",
+						"range_min": "10",
+						"range_max": "100",
+						"default":   "20",
+					}),
+					resource.TestCheckTypeSetElemNestedAttrs("opennebula_template.template", "user_input.*", map[string]string{
+						"name":      "FLAVOR",
+						"type":      "list",
+						"options.#": "3",
+						"options.0": "small",
+						"default":   "small",
+					}),
+					testAccCheckTemplateUserInputsEncoding(map[string]string{
+						"BLOG_TITLE": "M|text|Blog Title",
+						"DISK_SIZE":  "O|range|Disk size|10..100|20",
+						"FLAVOR":     "M|list|Flavor|small,medium,large|small",
+					}),
+				),
+			},
+			{
+				Config:      testAccTemplateUserInputInvalidDefault,
+				ExpectError: regexp.MustCompile("out of range"),
+			},
+		},
+	})
+}
+
+func testAccCheckTemplateUserInputsEncoding(expected map[string]string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		controller := testAccProvider.Meta().(*Configuration).Controller
+
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "opennebula_template" {
+				continue
+			}
+			tID, _ := strconv.ParseUint(rs.Primary.ID, 10, 0)
+			template, err := controller.Template(int(tID)).Info(false, false)
+			if err != nil {
+				return err
+			}
+
+			uInputs, err := template.Template.GetVector("USER_INPUTS")
+			if err != nil {
+				return err
+			}
+
+			for k, v := range expected {
+				value, err := uInputs.GetStr(k)
+				if err != nil {
+					return fmt.Errorf("user input %s not found: %s", k, err)
+				}
+				if value != v {
+					return fmt.Errorf("user input %s: expected %q, got %q", k, v, value)
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
 func testAccCheckTemplatePermissions(expected *shared.Permissions) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		config := testAccProvider.Meta().(*Configuration)
@@ -513,3 +632,71 @@ resource "opennebula_template" "template" {
   }
 }
 `
+
+var testAccTemplateVersioning = `
+resource "opennebula_template" "template" {
+  name = "terra-tpl-versioning"
+  permissions = "660"
+  group = "oneadmin"
+  cpu = "0.5"
+  memory = "%d"
+
+  versioning {
+    keep = 2
+  }
+}
+`
+
+var testAccTemplateUserInput = `
+resource "opennebula_template" "template" {
+  name = "terra-tpl-user-input"
+  permissions = "660"
+  group = "oneadmin"
+  cpu = "0.5"
+  memory = "128"
+
+  user_input {
+    name        = "BLOG_TITLE"
+    type        = "text"
+    mandatory   = true
+    description = "Blog Title"
+  }
+
+  user_input {
+    name        = "DISK_SIZE"
+    type        = "range"
+    description = "Disk size"
+    range_min   = 10
+    range_max   = 100
+    default     = "20"
+  }
+
+  user_input {
+    name        = "FLAVOR"
+    type        = "list"
+    mandatory   = true
+    description = "Flavor"
+    options     = ["small", "medium", "large"]
+    default     = "small"
+  }
+}
+`
+
+var testAccTemplateUserInputInvalidDefault = `
+resource "opennebula_template" "template" {
+  name = "terra-tpl-user-input"
+  permissions = "660"
+  group = "oneadmin"
+  cpu = "0.5"
+  memory = "128"
+
+  user_input {
+    name        = "DISK_SIZE"
+    type        = "range"
+    description = "Disk size"
+    range_min   = 10
+    range_max   = 100
+    default     = "200"
+  }
+}
+`
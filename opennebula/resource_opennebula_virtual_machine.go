@@ -70,7 +70,7 @@ func resourceOpennebulaVirtualMachine() *schema.Resource {
 		Exists:        resourceOpennebulaVirtualMachineExists,
 		UpdateContext: resourceOpennebulaVirtualMachineUpdate,
 		DeleteContext: resourceOpennebulaVirtualMachineDelete,
-		CustomizeDiff: resourceVMCustomizeDiff,
+		CustomizeDiff: resourceVMInstantiateCustomizeDiff,
 		Timeouts: &schema.ResourceTimeout{
 			Create: schema.DefaultTimeout(defaultVMTimeout),
 			Update: schema.DefaultTimeout(defaultVMTimeout),
@@ -124,6 +124,7 @@ func resourceOpennebulaVirtualMachine() *schema.Resource {
 				},
 				"template_nic":       templateNICVMSchema(),
 				"template_nic_alias": templateNICAliasVMSchema(),
+				"user_inputs_values": userInputsValuesSchema(),
 			},
 		),
 	}
@@ -460,6 +461,19 @@ func resourceOpennebulaVirtualMachineCreate(ctx context.Context, d *schema.Resou
 		addNICs(d, vmTpl)
 		addNICAliases(d, vmTpl)
 
+		userInputsValues := d.Get("user_inputs_values").(map[string]interface{})
+		if len(userInputsValues) > 0 {
+			err = applyUserInputsValues(&vmTpl.Template, &tpl.Template.Template, userInputsValues)
+			if err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  "Failed to set user inputs values",
+					Detail:   fmt.Sprintf("VM Template (ID: %d): %s", templateID, err),
+				})
+				return diags
+			}
+		}
+
 		log.Printf("[DEBUG] VM template: %s", vmTpl.String())
 
 		// Instantiate template without creating a persistent copy of the template
@@ -510,6 +524,13 @@ func resourceOpennebulaVirtualMachineCreate(ctx context.Context, d *schema.Resou
 				Detail:   "memory is mandatory when template_id is not defined",
 			})
 		}
+		if len(d.Get("user_inputs_values").(map[string]interface{})) > 0 {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "user_inputs_values can't be used",
+				Detail:   "user_inputs_values requires template_id to be defined",
+			})
+		}
 		if len(diags) > 0 {
 			return diags
 		}
@@ -3363,3 +3384,25 @@ func resourceVMCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, v i
 
 	return nil
 }
+
+func resourceVMInstantiateCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, v interface{}) error {
+
+	err := resourceVMCustomizeDiff(ctx, diff, v)
+	if err != nil {
+		return err
+	}
+
+	if !diff.NewValueKnown("template_id") || !diff.NewValueKnown("user_inputs_values") {
+		return nil
+	}
+
+	templateID := diff.Get("template_id").(int)
+	if templateID == -1 {
+		if len(diff.Get("user_inputs_values").(map[string]interface{})) > 0 {
+			return fmt.Errorf("user_inputs_values requires template_id to be defined")
+		}
+		return nil
+	}
+
+	return customizeDiffUserInputsValues(diff, v.(*Configuration).Controller, templateID)
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/OpenNebula/one/src/oca/go/src/goca/schemas/vm"
 	"os"
 	"reflect"
+	"regexp"
 	"strconv"
 	"testing"
 	"time"
@@ -477,6 +478,67 @@ func TestAccVirtualMachineTemplate(t *testing.T) {
 	})
 }
 
+func TestAccVirtualMachineTemplateUserInputs(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVirtualMachineDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccVirtualMachineTemplateUserInputsOutOfRange,
+				ExpectError: regexp.MustCompile("user input REPLICAS: \"10\" is out of range 1..5"),
+			},
+			{
+				Config:      testAccVirtualMachineTemplateUserInputsMissing,
+				ExpectError: regexp.MustCompile("user input BLOG_TITLE is mandatory"),
+			},
+			{
+				Config: testAccVirtualMachineTemplateUserInputsInstantiate,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_virtual_machine.test", "name", "test-virtual_machine"),
+					resource.TestCheckResourceAttr("opennebula_virtual_machine.test", "user_inputs_values.%", "2"),
+					resource.TestCheckResourceAttr("opennebula_virtual_machine.test", "user_inputs_values.BLOG_TITLE", "test blog"),
+					resource.TestCheckResourceAttr("opennebula_virtual_machine.test", "user_inputs_values.REPLICAS", "3"),
+					testAccCheckVirtualMachineUserInputsValues(map[string]string{
+						"BLOG_TITLE": "test blog",
+						"REPLICAS":   "3",
+					}),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckVirtualMachineUserInputsValues(expected map[string]string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		controller := testAccProvider.Meta().(*Configuration).Controller
+
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "opennebula_virtual_machine" {
+				continue
+			}
+
+			vmID, _ := strconv.ParseUint(rs.Primary.ID, 10, 64)
+			vmInfos, err := controller.VM(int(vmID)).Info(false)
+			if err != nil {
+				return err
+			}
+
+			for k, v := range expected {
+				value, err := vmInfos.UserTemplate.GetStr(k)
+				if err != nil {
+					return fmt.Errorf("VM (ID: %s): user input %s not found", rs.Primary.ID, k)
+				}
+				if value != v {
+					return fmt.Errorf("VM (ID: %s): user input %s value is %q, expected %q", rs.Primary.ID, k, value, v)
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
 func testAccCheckVirtualMachineDestroy(s *terraform.State) error {
 	config := testAccProvider.Meta().(*Configuration)
 	controller := config.Controller
@@ -1305,3 +1367,67 @@ resource "opennebula_virtual_machine" "test" {
 	sched_requirements = "CLUSTER_ID!=\"123\""
 }
 `
+
+var testAccVirtualMachineTemplateUserInputs = `
+resource "opennebula_template" "template" {
+  name = "terratpluserinputs"
+  permissions = "642"
+  group = "oneadmin"
+
+  cpu = "1"
+  vcpu = "1"
+  memory = "768"
+
+  context = {
+	network = "YES"
+	blog_title = "$BLOG_TITLE"
+	replicas = "$REPLICAS"
+  }
+
+  user_inputs = {
+	BLOG_TITLE = "M|text|Blog Title"
+	REPLICAS = "O|range|Number of replicas|1..5|1"
+  }
+}
+`
+
+var testAccVirtualMachineTemplateUserInputsOutOfRange = testAccVirtualMachineTemplateUserInputs + `
+resource "opennebula_virtual_machine" "test" {
+	name        = "test-virtual_machine"
+	group       = "oneadmin"
+
+	template_id = opennebula_template.template.id
+
+	user_inputs_values = {
+		BLOG_TITLE = "test blog"
+		REPLICAS = "10"
+	}
+}
+`
+
+var testAccVirtualMachineTemplateUserInputsMissing = testAccVirtualMachineTemplateUserInputs + `
+resource "opennebula_virtual_machine" "test" {
+	name        = "test-virtual_machine"
+	group       = "oneadmin"
+
+	template_id = opennebula_template.template.id
+
+	user_inputs_values = {
+		REPLICAS = "3"
+	}
+}
+`
+
+var testAccVirtualMachineTemplateUserInputsInstantiate = testAccVirtualMachineTemplateUserInputs + `
+resource "opennebula_virtual_machine" "test" {
+	name        = "test-virtual_machine"
+	group       = "oneadmin"
+
+	template_id = opennebula_template.template.id
+
+	user_inputs_values = {
+		BLOG_TITLE = "test blog"
+		REPLICAS = "3"
+	}
+}
+`
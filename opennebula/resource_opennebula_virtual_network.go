@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -40,12 +41,13 @@ func resourceOpennebulaVirtualNetwork() *schema.Resource {
 		DeleteContext: resourceOpennebulaVirtualNetworkDelete,
 		Timeouts: &schema.ResourceTimeout{
 			Create: schema.DefaultTimeout(defaultVNetTimeout),
+			Update: schema.DefaultTimeout(defaultVNetTimeout),
 		},
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
 		},
-		CustomizeDiff: SetTagsDiff,
-		Schema: map[string]*schema.Schema{
+		CustomizeDiff: resourceVnetCustomizeDiff,
+		Schema: mergeSchemas(map[string]*schema.Schema{
 			"name": {
 				Type:        schema.TypeString,
 				Required:    true,
@@ -54,6 +56,7 @@ func resourceOpennebulaVirtualNetwork() *schema.Resource {
 			"description": {
 				Type:        schema.TypeString,
 				Optional:    true,
+				Computed:    true,
 				Description: "Description of the vnet",
 			},
 			"permissions": {
@@ -118,11 +121,11 @@ func resourceOpennebulaVirtualNetwork() *schema.Resource {
 			"type": {
 				Type:          schema.TypeString,
 				Optional:      true,
-				Default:       "bridge",
-				Description:   "Type of the Virtual Network: dummy, bridge, fw, ebtables, 802.1Q, vxlan, ovswitch. Default is 'bridge'",
+				Computed:      true,
+				Description:   "Type of the Virtual Network: dummy, bridge, fw, ebtables, 802.1Q, vxlan, ovswitch, ovswitch_vxlan. Default is 'bridge', or the template one when 'template_id' is set",
 				ConflictsWith: []string{"reservation_vnet", "reservation_size", "reservation_ar_id", "reservation_first_ip", "reservation_first_ip6"},
 				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
-					validtypes := []string{"dummy", "bridge", "fw", "ebtables", "802.1Q", "vxlan", "ovswitch"}
+					validtypes := []string{"dummy", "bridge", "fw", "ebtables", "802.1Q", "vxlan", "ovswitch", "ovswitch_vxlan"}
 					value := v.(string)
 
 					if !contains(value, validtypes) {
@@ -135,7 +138,8 @@ func resourceOpennebulaVirtualNetwork() *schema.Resource {
 			"cluster_ids": {
 				Type:          schema.TypeSet,
 				Optional:      true,
-				Description:   "List of cluster IDs hosting the virtual Network",
+				Computed:      true,
+				Description:   "List of cluster IDs hosting the virtual Network, if not set the membership can be managed with opennebula_cluster_virtual_network",
 				ConflictsWith: []string{"reservation_vnet", "reservation_size", "reservation_ar_id", "reservation_first_ip"},
 				Elem: &schema.Schema{
 					Type: schema.TypeInt,
@@ -159,44 +163,49 @@ func resourceOpennebulaVirtualNetwork() *schema.Resource {
 			"mtu": {
 				Type:          schema.TypeInt,
 				Optional:      true,
-				Description:   "MTU of the vnet (defaut: 1500)",
-				Default:       1500,
+				Computed:      true,
+				Description:   "MTU of the vnet (defaut: 1500, or the template one when 'template_id' is set)",
 				ConflictsWith: []string{"reservation_vnet", "reservation_size", "reservation_ar_id", "reservation_first_ip", "reservation_first_ip6"},
 			},
 			"guest_mtu": {
 				Type:          schema.TypeInt,
 				Optional:      true,
-				Description:   "MTU of the Guest interface. Must be lower or equal to 'mtu' (defaut: 1500)",
-				Default:       1500,
+				Computed:      true,
+				Description:   "MTU of the Guest interface. Must be lower or equal to 'mtu' (defaut: 1500, or the template one when 'template_id' is set)",
 				ConflictsWith: []string{"reservation_vnet", "reservation_size", "reservation_ar_id", "reservation_first_ip", "reservation_first_ip6"},
 			},
 			"gateway": {
 				Type:          schema.TypeString,
 				Optional:      true,
+				Computed:      true,
 				Description:   "Gateway IP if necessary",
 				ConflictsWith: []string{"reservation_vnet", "reservation_size", "reservation_ar_id", "reservation_first_ip", "reservation_first_ip6"},
 			},
 			"network_mask": {
 				Type:          schema.TypeString,
 				Optional:      true,
+				Computed:      true,
 				Description:   "Network Mask",
 				ConflictsWith: []string{"reservation_vnet", "reservation_size", "reservation_ar_id", "reservation_first_ip", "reservation_first_ip6"},
 			},
 			"network_address": {
 				Type:          schema.TypeString,
 				Optional:      true,
+				Computed:      true,
 				Description:   "Network Address",
 				ConflictsWith: []string{"reservation_vnet", "reservation_size"},
 			},
 			"search_domain": {
 				Type:          schema.TypeString,
 				Optional:      true,
+				Computed:      true,
 				Description:   "Search Domain",
 				ConflictsWith: []string{"reservation_vnet", "reservation_size"},
 			},
 			"dns": {
 				Type:          schema.TypeString,
 				Optional:      true,
+				Computed:      true,
 				Description:   "DNS IP if necessary",
 				ConflictsWith: []string{"reservation_vnet", "reservation_size", "reservation_ar_id", "reservation_first_ip", "reservation_first_ip6"},
 			},
@@ -227,24 +236,28 @@ func resourceOpennebulaVirtualNetwork() *schema.Resource {
 				Description:   "Create a reservation from this VNET ID",
 				ConflictsWith: []string{"bridge", "physical_device", "ar", "hold_ips", "type", "vlan_id", "automatic_vlan_id", "mtu", "dns", "gateway", "network_mask", "network_address", "search_domain"},
 				Default:       -1,
+				Deprecated:    "use the opennebula_virtual_network_reservation resource instead",
 			},
 			"reservation_size": {
 				Type:          schema.TypeInt,
 				Optional:      true,
 				Description:   "Reserve this many IPs from reservation_vnet",
 				ConflictsWith: []string{"bridge", "physical_device", "ar", "hold_ips", "type", "vlan_id", "automatic_vlan_id", "mtu", "dns", "gateway", "network_mask", "network_address", "search_domain"},
+				Deprecated:    "use the opennebula_virtual_network_reservation resource instead",
 			},
 			"reservation_first_ip": {
 				Type:          schema.TypeString,
 				Optional:      true,
 				Description:   "First IP of the reservation",
 				ConflictsWith: []string{"bridge", "physical_device", "ar", "hold_ips", "type", "vlan_id", "automatic_vlan_id", "mtu", "dns", "gateway", "network_mask"},
+				Deprecated:    "use the opennebula_virtual_network_reservation resource instead",
 			},
 			"reservation_first_ip6": {
 				Type:          schema.TypeString,
 				Optional:      true,
 				Description:   "First IP6 of the reservation",
 				ConflictsWith: []string{"bridge", "physical_device", "ar", "hold_ips", "type", "vlan_id", "automatic_vlan_id", "mtu", "dns", "gateway", "network_mask"},
+				Deprecated:    "use the opennebula_virtual_network_reservation resource instead",
 			},
 			"reservation_ar_id": {
 				Type:          schema.TypeInt,
@@ -252,6 +265,15 @@ func resourceOpennebulaVirtualNetwork() *schema.Resource {
 				Default:       -1,
 				Description:   "Address Range ID to be used for the reservation",
 				ConflictsWith: []string{"bridge", "physical_device", "ar", "hold_ips", "type", "vlan_id", "automatic_vlan_id", "mtu", "dns", "gateway", "network_mask"},
+				Deprecated:    "use the opennebula_virtual_network_reservation resource instead",
+			},
+			"template_id": {
+				Type:          schema.TypeInt,
+				Optional:      true,
+				ForceNew:      true,
+				Default:       -1,
+				Description:   "Instantiate the VNET from this virtual network template ID",
+				ConflictsWith: []string{"reservation_vnet", "reservation_size", "reservation_ar_id", "reservation_first_ip", "reservation_first_ip6", "bridge", "physical_device", "ar", "hold_ips", "vlan_id", "automatic_vlan_id", "cluster_ids"},
 			},
 			"security_groups": {
 				Type:        schema.TypeSet,
@@ -277,7 +299,7 @@ func resourceOpennebulaVirtualNetwork() *schema.Resource {
 			"default_tags":     defaultTagsSchemaComputed(),
 			"tags_all":         tagsSchemaComputed(),
 			"template_section": templateSectionSchema(),
-		},
+		}, vnetDriverSchemas([]string{"reservation_vnet", "reservation_size", "reservation_ar_id", "reservation_first_ip", "reservation_first_ip6", "template_id"})),
 	}
 }
 
@@ -532,6 +554,51 @@ func resourceOpennebulaVirtualNetworkCreate(ctx context.Context, d *schema.Resou
 
 		log.Printf("[DEBUG] New VNET reservation ID: %d", vnet.ID)
 
+	} else if templateID := d.Get("template_id").(int); templateID > -1 { // VNET from a template
+
+		// the configured attributes override the template ones
+		extraTpl, err := generateVnTemplate(d, meta)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to generate template description",
+				Detail:   err.Error(),
+			})
+			return diags
+		}
+
+		vnetID, err := controller.VNTemplate(templateID).Instantiate(d.Get("name").(string), extraTpl)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to instantiate the virtual network template",
+				Detail:   fmt.Sprintf("virtual network template (ID: %d): %s", templateID, err),
+			})
+			return diags
+		}
+		vnc = controller.VirtualNetwork(vnetID)
+
+		d.SetId(fmt.Sprintf("%v", vnetID))
+
+		// virtual network states were introduce with OpenNebula 6.4 release
+		requiredVersion, _ := version.NewVersion("6.4.0")
+
+		if config.OneVersion.GreaterThanOrEqual(requiredVersion) {
+			timeout := d.Timeout(schema.TimeoutCreate)
+			transient := []string{vn.Init.String(), vn.LockCreate.String()}
+			_, err = waitForVNetworkState(ctx, vnc, timeout, transient, []string{vn.Ready.String()})
+			if err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  "Failed to wait virtual network to be in READY state",
+					Detail:   fmt.Sprintf("virtual network (ID: %s): %s", d.Id(), err),
+				})
+				return diags
+			}
+		}
+
+		log.Printf("[DEBUG] New VNET ID: %d, from template ID: %d", vnetID, templateID)
+
 	} else { //New VNET
 		vnDef, err := generateVn(d)
 		if err != nil {
@@ -772,20 +839,86 @@ func vnetGenerateAR(armap map[string]interface{}) *vn.AddressRange {
 	return ar
 }
 
+// vnetDefaults are the values of the attributes a virtual network template may
+// define, when they aren't configured
+var vnetDefaults = map[string]interface{}{
+	"type":            "bridge",
+	"mtu":             1500,
+	"guest_mtu":       1500,
+	"description":     "",
+	"gateway":         "",
+	"network_mask":    "",
+	"network_address": "",
+	"search_domain":   "",
+	"dns":             "",
+}
+
+func resourceVnetCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	// reservations don't manage these attributes, they are the parent ones
+	if diff.Get("reservation_vnet").(int) == -1 {
+		err := vnetDefaultsCustomizeDiff(diff)
+		if err != nil {
+			return err
+		}
+	}
+
+	return vnetDriverCustomizeDiff(ctx, diff, meta)
+}
+
+// vnetDefaultsCustomizeDiff resets the unconfigured attributes to their defaults
+func vnetDefaultsCustomizeDiff(diff *schema.ResourceDiff) error {
+
+	// when the vnet is instantiated from a template, the unconfigured attributes
+	// are inherited from it instead of being reset to their defaults
+	fromTemplate := diff.Get("template_id").(int) > -1
+	rawConfig := diff.GetRawConfig()
+
+	for attr, value := range vnetDefaults {
+		if !rawConfig.IsNull() && rawConfig.IsKnown() && !rawConfig.GetAttr(attr).IsNull() {
+			continue
+		}
+
+		var err error
+		if fromTemplate {
+			if len(diff.Id()) == 0 {
+				err = diff.SetNewComputed(attr)
+			}
+		} else if diff.Get(attr) != value {
+			err = diff.SetNew(attr, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func generateVnTemplate(d *schema.ResourceData, meta interface{}) (string, error) {
 	config := meta.(*Configuration)
 
 	tpl := vn.NewTemplate()
 
+	// the MTUs are unset when they are inherited from a virtual network template
 	mtu := d.Get("mtu").(int)
 	guestMTU := d.Get("guest_mtu").(int)
 
-	if guestMTU > mtu {
+	if mtu > 0 && guestMTU > mtu {
 		return "", fmt.Errorf("Invalid: Guest MTU (%v) is greater than MTU (%v)", guestMTU, mtu)
 	}
 
-	tpl.AddPair("MTU", mtu)
-	tpl.AddPair(string(vnk.GuestMTU), guestMTU)
+	if mtu > 0 {
+		tpl.AddPair("MTU", mtu)
+	}
+	if guestMTU > 0 {
+		tpl.AddPair(string(vnk.GuestMTU), guestMTU)
+	}
+
+	qos := getVnetDriverBlock(d, "qos")
+	if qos != nil {
+		updateVnetDriverAttributes("qos", qos, tpl)
+	}
 
 	if dns, ok := d.GetOk("dns"); ok {
 		tpl.Add(vnk.DNS, dns.(string))
@@ -866,6 +999,19 @@ func generateVn(d *schema.ResourceData) (string, error) {
 	if vnphydev, ok := d.GetOk("physical_device"); ok {
 		tpl.Add(vnk.PhyDev, vnphydev.(string))
 	}
+	if outerVlanID, ok := d.GetOk("outer_vlan_id"); ok {
+		tpl.Add("OUTER_VLAN_ID", outerVlanID.(string))
+	}
+	if bridgeType, ok := d.GetOk("bridge_type"); ok {
+		tpl.Add("BRIDGE_TYPE", bridgeType.(string))
+	}
+
+	for block := range vnetDriverBlocks {
+		attrs := getVnetDriverBlock(d, block)
+		if attrs != nil {
+			updateVnetDriverAttributes(block, attrs, tpl)
+		}
+	}
 
 	tplStr := tpl.String()
 	log.Printf("[INFO] VNET definition: %s", tplStr)
@@ -967,10 +1113,24 @@ func resourceOpennebulaVirtualNetworkRead(ctx context.Context, d *schema.Resourc
 	reservationVNet := d.Get("reservation_vnet").(int)
 	isReservation := reservationVNet > -1 && len(vn.ParentNetworkID) > 0
 
+	// driver attributes are inherited from the virtual network template
+	fromTemplate := d.Get("template_id").(int) > -1
+
 	if !isReservation {
 		d.Set("type", vn.VNMad)
 	}
 
+	if !isReservation && !fromTemplate {
+		err := flattenVnetDriverSchemas(d, &vn.Template)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Failed to flatten driver attributes",
+				Detail:   fmt.Sprintf("virtual network (ID: %s): %s", d.Id(), err),
+			})
+		}
+	}
+
 	flattenDiags := flattenVnetTemplate(d, meta, isReservation, &vn.Template)
 	if len(flattenDiags) > 0 {
 		diags = append(diags, flattenDiags...)
@@ -1525,6 +1685,119 @@ func resourceOpennebulaVirtualNetworkUpdate(ctx context.Context, d *schema.Resou
 		update = true
 	}
 
+	driverUpdate := false
+
+	if d.HasChange("type") {
+		vnmad := d.Get("type").(string)
+		_, vlanSet := d.GetOk("vlan_id")
+		if mandatoryVLAN(vnmad) && !vlanSet && !d.Get("automatic_vlan_id").(bool) {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to update the virtual network type",
+				Detail:   fmt.Sprintf("virtual network (ID: %s): you must specify a 'vlan_id' or set the flag 'automatic_vlan_id'", d.Id()),
+			})
+			return diags
+		}
+		tpl.Del(string(vnk.VNMad))
+		tpl.Add(vnk.VNMad, vnmad)
+		driverUpdate = true
+	}
+
+	if d.HasChange("bridge") {
+		tpl.Del(string(vnk.Bridge))
+		bridge := d.Get("bridge").(string)
+		if len(bridge) > 0 {
+			tpl.Add(vnk.Bridge, bridge)
+		}
+		driverUpdate = true
+	}
+
+	if d.HasChange("physical_device") {
+		tpl.Del(string(vnk.PhyDev))
+		phyDev := d.Get("physical_device").(string)
+		if len(phyDev) > 0 {
+			tpl.Add(vnk.PhyDev, phyDev)
+		}
+		driverUpdate = true
+	}
+
+	if d.HasChange("vlan_id") {
+		tpl.Del(string(vnk.VlanID))
+		vlanID := d.Get("vlan_id").(string)
+		if len(vlanID) > 0 {
+			tpl.Add(vnk.VlanID, vlanID)
+		}
+		driverUpdate = true
+	}
+
+	if d.HasChange("outer_vlan_id") {
+		tpl.Del("OUTER_VLAN_ID")
+		outerVlanID := d.Get("outer_vlan_id").(string)
+		if len(outerVlanID) > 0 {
+			tpl.Add("OUTER_VLAN_ID", outerVlanID)
+		}
+		driverUpdate = true
+	}
+
+	// an unknown bridge type lets OpenNebula choose the bridge of the new type
+	if d.HasChange("bridge_type") {
+		tpl.Del("BRIDGE_TYPE")
+		bridgeType := d.Get("bridge_type").(string)
+		if len(bridgeType) > 0 {
+			tpl.Add("BRIDGE_TYPE", bridgeType)
+		}
+		driverUpdate = true
+	}
+
+	for _, block := range []string{"vxlan", "qinq", "qos"} {
+		if d.HasChange(block) {
+			updateVnetDriverAttributes(block, getVnetDriverBlock(d, block), &tpl)
+			driverUpdate = true
+		}
+	}
+
+	if d.HasChanges("mtu", "guest_mtu") {
+		mtu := d.Get("mtu").(int)
+		guestMTU := d.Get("guest_mtu").(int)
+
+		if guestMTU > mtu {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to update the MTU",
+				Detail:   fmt.Sprintf("virtual network (ID: %s): Guest MTU (%v) is greater than MTU (%v)", d.Id(), guestMTU, mtu),
+			})
+			return diags
+		}
+
+		tpl.Del("MTU")
+		tpl.AddPair("MTU", mtu)
+		tpl.Del(string(vnk.GuestMTU))
+		tpl.AddPair(string(vnk.GuestMTU), guestMTU)
+		driverUpdate = true
+	}
+
+	if driverUpdate {
+		update = true
+
+		// the NICs keep the bridge and the driver they were attached with
+		if d.HasChanges("type", "bridge", "bridge_type") {
+			vmIDs, err := vnetAttachedVMs(controller, vnInfos.ID)
+			if err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Warning,
+					Summary:  "Failed to retrieve the virtual machines using the network",
+					Detail:   fmt.Sprintf("virtual network (ID: %s): %s", d.Id(), err),
+				})
+			} else if len(vmIDs) > 0 {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Warning,
+					Summary:  "Virtual machine NICs need to be reattached",
+					Detail:   fmt.Sprintf("virtual network (ID: %s): the type, the bridge or the bridge type changed, detach and reattach the NICs of the virtual machines %v to apply it", d.Id(), vmIDs),
+				})
+			}
+		}
+	}
+
 	if update {
 		err := vnc.Update(tpl.String(), parameters.Replace)
 		if err != nil {
@@ -1537,6 +1810,23 @@ func resourceOpennebulaVirtualNetworkUpdate(ctx context.Context, d *schema.Resou
 		}
 	}
 
+	// virtual network states were introduce with OpenNebula 6.4 release
+	requiredVersion, _ := version.NewVersion("6.4.0")
+
+	if driverUpdate && config.OneVersion.GreaterThanOrEqual(requiredVersion) {
+		// the drivers reconfigure the hosts of the virtual machines using the network
+		timeout := d.Timeout(schema.TimeoutUpdate)
+		_, err = waitForVNetworkUpdate(ctx, vnc, timeout)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to wait virtual network update",
+				Detail:   fmt.Sprintf("virtual network (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
 	if d.HasChange("name") {
 		err := vnc.Rename(d.Get("name").(string))
 		if err != nil {
@@ -1712,7 +2002,27 @@ func resourceOpennebulaVirtualNetworkUpdate(ctx context.Context, d *schema.Resou
 		}
 	}
 
-	return resourceOpennebulaVirtualNetworkRead(ctx, d, meta)
+	return append(diags, resourceOpennebulaVirtualNetworkRead(ctx, d, meta)...)
+}
+
+// vnetAttachedVMs returns the IDs of the virtual machines having a lease on the virtual network
+func vnetAttachedVMs(controller *goca.Controller, vnetID int) ([]int, error) {
+	owners, err := getVnetLeasesOwners(controller, vnetID)
+	if err != nil {
+		return nil, err
+	}
+
+	vmIDs := make([]int, 0)
+	for _, owner := range owners {
+		ownerType, ownerID := vnetLeaseOwner(owner)
+		if ownerType != "VM" {
+			continue
+		}
+		vmIDs = append(vmIDs, ownerID)
+	}
+	sort.Ints(vmIDs)
+
+	return vmIDs, nil
 }
 
 func resourceOpennebulaVirtualNetworkDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
@@ -31,6 +31,7 @@ func resourceOpennebulaVirtualNetworkAddressRange() *schema.Resource {
 		Importer: &schema.ResourceImporter{
 			StateContext: resourceOpennebulaVirtualNetworkAddressRangeImportState,
 		},
+		CustomizeDiff: resourceVirtualNetworkAddressRangeCustomizeDiff,
 
 		Schema: map[string]*schema.Schema{
 			"virtual_network_id": {
@@ -57,7 +58,8 @@ func resourceOpennebulaVirtualNetworkAddressRange() *schema.Resource {
 			"ip4": {
 				Type:        schema.TypeString,
 				Optional:    true,
-				Description: "Start IPv4 of the range to be allocated (Required if IP4 or IP4_6).",
+				Computed:    true,
+				Description: "Start IPv4 of the range to be allocated (Required if IP4 or IP4_6), allocated by the IPAM driver if not set",
 			},
 			"size": {
 				Type:        schema.TypeInt,
@@ -67,7 +69,8 @@ func resourceOpennebulaVirtualNetworkAddressRange() *schema.Resource {
 			"ip6": {
 				Type:        schema.TypeString,
 				Optional:    true,
-				Description: "Start IPv6 of the range to be allocated (Required if IP6_STATIC or IP4_6_STATIC)",
+				Computed:    true,
+				Description: "Start IPv6 of the range to be allocated (Required if IP6_STATIC or IP4_6_STATIC), allocated by the IPAM driver if not set",
 			},
 			"mac": {
 				Type:        schema.TypeString,
@@ -78,12 +81,14 @@ func resourceOpennebulaVirtualNetworkAddressRange() *schema.Resource {
 			"global_prefix": {
 				Type:        schema.TypeString,
 				Optional:    true,
-				Description: "Global prefix for IP6 or IP4_6",
+				Computed:    true,
+				Description: "Global prefix for IP6 or IP4_6, allocated by the IPAM driver if not set",
 			},
 			"ula_prefix": {
 				Type:        schema.TypeString,
 				Optional:    true,
-				Description: "ULA prefix for IP6 or IP4_6",
+				Computed:    true,
+				Description: "ULA prefix for IP6 or IP4_6, allocated by the IPAM driver if not set",
 			},
 			"prefix_length": {
 				Type:        schema.TypeString,
@@ -111,6 +116,14 @@ func resourceOpennebulaVirtualNetworkAddressRange() *schema.Resource {
 				Optional:    true,
 				Description: "IPAM driver",
 			},
+			"ipam_attributes": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "Attributes returned by the IPAM driver",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 			"shared": {
 				Type:        schema.TypeBool,
 				Optional:    true,
@@ -129,6 +142,42 @@ func resourceOpennebulaVirtualNetworkAddressRange() *schema.Resource {
 	}
 }
 
+// vNetARIPAMAllocatedAttrs are the address attributes that an IPAM driver may allocate
+var vNetARIPAMAllocatedAttrs = []string{"ip4", "ip6", "global_prefix", "ula_prefix"}
+
+// resourceVirtualNetworkAddressRangeCustomizeDiff only lets the unset addresses be
+// computed when an IPAM driver allocates them
+func resourceVirtualNetworkAddressRangeCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	rawConfig := diff.GetRawConfig()
+	if rawConfig.IsNull() || !rawConfig.IsKnown() {
+		return nil
+	}
+
+	ipamKnown := diff.NewValueKnown("ipam")
+	ipam := diff.Get("ipam").(string)
+
+	for _, attr := range vNetARIPAMAllocatedAttrs {
+		if !rawConfig.GetAttr(attr).IsNull() {
+			continue
+		}
+
+		var err error
+		switch {
+		case ipamKnown && len(ipam) == 0:
+			// without IPAM driver an unset address is removed from the address range
+			err = diff.SetNew(attr, "")
+		case len(diff.Id()) == 0 || diff.HasChange("ipam"):
+			err = diff.SetNewComputed(attr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func resourceOpennebulaVirtualNetworkAddressRangeCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
@@ -236,6 +285,16 @@ func generateAR(d *schema.ResourceData) *vn.AddressRange {
 		ar.Add(vnk.PrefixLength, arprefixlength)
 	}
 
+	// let the IPAM driver allocate the addresses that are not set
+	if ipam != "" {
+		if arip4 == "" {
+			ar.Del(string(vnk.IP))
+		}
+		if arip6 == "" {
+			ar.Del("IP6")
+		}
+	}
+
 	customIf := d.Get("custom").(map[string]interface{})
 
 	for k, v := range customIf {
@@ -312,6 +371,7 @@ func resourceOpennebulaVirtualNetworkAddressRangeRead(ctx context.Context, d *sc
 	// OpenNebula translate keys to uppercases so we need to retrieve the original case from the configuration
 	customCfg := d.Get("custom").(map[string]interface{})
 	custom := make(map[string]interface{})
+	ipamAttributes := make(map[string]interface{})
 
 	for _, pair := range ar.Custom {
 
@@ -320,16 +380,29 @@ func resourceOpennebulaVirtualNetworkAddressRangeRead(ctx context.Context, d *sc
 			d.Set("ipam", pair.Value)
 		default:
 			// retrieve the case of the key from the configuration
+			configured := false
 			for k, _ := range customCfg {
 				if strings.ToUpper(k) == pair.Key() {
 					custom[k] = pair.Value
+					configured = true
 					break
 				}
 			}
+
+			// the other attributes are returned by the IPAM driver
+			if !configured {
+				ipamAttributes[strings.ToLower(pair.Key())] = pair.Value
+			}
 		}
 	}
 	d.Set("custom", custom)
 
+	if len(d.Get("ipam").(string)) > 0 {
+		d.Set("ipam_attributes", ipamAttributes)
+	} else {
+		d.Set("ipam_attributes", map[string]interface{}{})
+	}
+
 	return nil
 }
 
@@ -372,7 +445,7 @@ func resourceOpennebulaVirtualNetworkAddressRangeUpdate(ctx context.Context, d *
 	// some attributes update require to detach - reattach the AR
 	updated := false
 	if d.HasChange("ar_type") || d.HasChange("ip4") ||
-		d.HasChange("ip6") {
+		d.HasChange("ip6") || d.HasChange("ipam") {
 
 		arID, err := strconv.ParseUint(d.Id(), 10, 0)
 		if err != nil {
@@ -410,7 +483,7 @@ func resourceOpennebulaVirtualNetworkAddressRangeUpdate(ctx context.Context, d *
 	// in-place updates
 	if !updated && (d.HasChange("mac") || d.HasChange("size") ||
 		d.HasChange("global_prefix") || d.HasChange("ula_prefix") ||
-		d.HasChange("prefix_length") || d.HasChange("ipam") ||
+		d.HasChange("prefix_length") ||
 		d.HasChange("shared") ||
 		d.HasChange("custom")) {
 
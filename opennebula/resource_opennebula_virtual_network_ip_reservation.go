@@ -0,0 +1,417 @@
+package opennebula
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/dynamic"
+	vn "github.com/OpenNebula/one/src/oca/go/src/goca/schemas/virtualnetwork"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourceOpennebulaVirtualNetworkIPReservation() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceOpennebulaVirtualNetworkIPReservationCreate,
+		ReadContext:   resourceOpennebulaVirtualNetworkIPReservationRead,
+		DeleteContext: resourceOpennebulaVirtualNetworkIPReservationDelete,
+		Timeouts: &schema.ResourceTimeout{
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		CustomizeDiff: resourceVirtualNetworkIPReservationCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"virtual_network_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the virtual network",
+			},
+			"ar_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the address range to reserve the IPs from",
+			},
+			"size": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     1,
+				Description: "Number of IPs to reserve",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					if v.(int) < 1 {
+						errors = append(errors, fmt.Errorf("%q must be greater than 0", k))
+					}
+					return
+				},
+			},
+			"ips": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "IPs reserved",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"reservation_vnet_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "ID of the reservation virtual network holding the IPs, to use as the network of the NICs",
+			},
+		},
+	}
+}
+
+func vnetLeasesKey(vNetID int) *SubResourceKey {
+	return &SubResourceKey{
+		Type:    "vnet",
+		ID:      vNetID,
+		SubType: "leases",
+	}
+}
+
+// freeIPv4s returns up to count addresses of the IPv4 range that are not used
+func freeIPv4s(start string, size int, used map[string]bool, count int) ([]string, error) {
+
+	startIP := net.ParseIP(start).To4()
+	if startIP == nil {
+		return nil, fmt.Errorf("%q is not an IPv4 address", start)
+	}
+	first := binary.BigEndian.Uint32(startIP)
+
+	ips := make([]string, 0, count)
+	for i := 0; i < size && len(ips) < count; i++ {
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, first+uint32(i))
+
+		if used[ip.String()] {
+			continue
+		}
+		ips = append(ips, ip.String())
+	}
+
+	return ips, nil
+}
+
+func getVNetAR(vnInfos *vn.VirtualNetwork, arID int) *vn.AR {
+	for i, ar := range vnInfos.ARs {
+		if ar.ID == fmt.Sprint(arID) {
+			return &vnInfos.ARs[i]
+		}
+	}
+	return nil
+}
+
+// arIPv4Types are the address range types with IPv4 addresses to hold
+var arIPv4Types = []string{"IP4", "IP4_6", "IP4_6_STATIC"}
+
+// checkVNetARIPv4 returns an error when the address range has no IPv4 address
+func checkVNetARIPv4(ar *vn.AR) error {
+	if !contains(ar.Type, arIPv4Types) {
+		return fmt.Errorf("address range (ID: %s) of type %s has no IPv4 address, allowed types: %s", ar.ID, ar.Type, strings.Join(arIPv4Types, ","))
+	}
+	return nil
+}
+
+// resourceVirtualNetworkIPReservationCustomizeDiff checks the type of the address range
+// when the virtual network already exists
+func resourceVirtualNetworkIPReservationCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	if diff.Id() != "" || !diff.NewValueKnown("virtual_network_id") || !diff.NewValueKnown("ar_id") {
+		return nil
+	}
+
+	vNetID := diff.Get("virtual_network_id").(int)
+	arID := diff.Get("ar_id").(int)
+
+	vnInfos, err := meta.(*Configuration).Controller.VirtualNetwork(vNetID).Info(false)
+	if err != nil {
+		// the virtual network may be created by the same apply
+		if NoExists(err) {
+			return nil
+		}
+		return fmt.Errorf("virtual network (ID: %d): %s", vNetID, err)
+	}
+
+	ar := getVNetAR(vnInfos, arID)
+	if ar == nil {
+		return nil
+	}
+
+	return checkVNetARIPv4(ar)
+}
+
+// reserveIP reserves an IP of the address range into the reservation virtual network,
+// which is created when reservationID is -1, and returns the reservation ID
+func reserveIP(vnc *goca.VirtualNetworkController, arID int, ip string, name string, reservationID int) (int, error) {
+	tpl := dynamic.NewTemplate()
+	tpl.AddPair("SIZE", 1)
+	tpl.AddPair("AR_ID", arID)
+	tpl.AddPair("IP", ip)
+
+	if reservationID > -1 {
+		tpl.AddPair("NETWORK_ID", reservationID)
+	} else {
+		tpl.AddPair("NAME", name)
+	}
+
+	return vnc.Reserve(tpl.String())
+}
+
+func resourceOpennebulaVirtualNetworkIPReservationCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	vNetID := d.Get("virtual_network_id").(int)
+	arID := d.Get("ar_id").(int)
+	size := d.Get("size").(int)
+
+	// serialize the reservations on the virtual network so they don't pick the same IPs
+	leasesKey := vnetLeasesKey(vNetID)
+	config.mutex.Lock(leasesKey)
+	defer config.mutex.Unlock(leasesKey)
+
+	vnc := controller.VirtualNetwork(vNetID)
+
+	vnInfos, err := vnc.Info(false)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("Virtual network (ID: %d): %s", vNetID, err),
+		})
+		return diags
+	}
+
+	ar := getVNetAR(vnInfos, arID)
+	if ar == nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "virtual network address range not found",
+			Detail:   fmt.Sprintf("Virtual network (ID: %d): AR (ID: %d) not found", vNetID, arID),
+		})
+		return diags
+	}
+
+	err = checkVNetARIPv4(ar)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Invalid address range type",
+			Detail:   fmt.Sprintf("Virtual network (ID: %d): %s", vNetID, err),
+		})
+		return diags
+	}
+
+	used := make(map[string]bool, len(ar.Leases))
+	for _, lease := range ar.Leases {
+		used[lease.IP] = true
+	}
+
+	// an IP may be leased outside of Terraform meanwhile, try the next free ones
+	reservationID := -1
+	ips := make([]string, 0, size)
+	for len(ips) < size {
+		candidates, err := freeIPv4s(ar.IP, ar.Size, used, size-len(ips))
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to list the free IPs of the address range",
+				Detail:   fmt.Sprintf("Virtual network (ID: %d) address range (ID: %d): %s", vNetID, arID, err),
+			})
+			break
+		}
+		if len(candidates) == 0 {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Not enough free IPs in the address range",
+				Detail:   fmt.Sprintf("Virtual network (ID: %d) address range (ID: %d): %d IPs requested, %d reserved", vNetID, arID, size, len(ips)),
+			})
+			break
+		}
+
+		for _, ip := range candidates {
+			used[ip] = true
+
+			name := fmt.Sprintf("%s-ip-reservation-%s", vnInfos.Name, ip)
+			rID, err := reserveIP(vnc, arID, ip, name, reservationID)
+			if err != nil {
+				log.Printf("[DEBUG] Failed to reserve IP %s: %s", ip, err)
+				continue
+			}
+
+			reservationID = rID
+			ips = append(ips, ip)
+		}
+	}
+
+	if len(diags) > 0 {
+		if reservationID > -1 {
+			err := controller.VirtualNetwork(reservationID).Delete()
+			if err != nil {
+				log.Printf("[WARN] Failed to delete the reservation virtual network %d: %s", reservationID, err)
+			}
+		}
+		return diags
+	}
+
+	d.SetId(fmt.Sprint(reservationID))
+
+	log.Printf("[INFO] Successfully reserved IPs %v of virtual network %d into virtual network %d\n", ips, vNetID, reservationID)
+
+	return resourceOpennebulaVirtualNetworkIPReservationRead(ctx, d, meta)
+}
+
+func resourceOpennebulaVirtualNetworkIPReservationRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	rID, err := strconv.ParseInt(d.Id(), 10, 0)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "can't parse IP reservation ID",
+			Detail:   fmt.Sprintf("%s is not an ID: %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	reservation, err := controller.VirtualNetwork(int(rID)).Info(false)
+	if err != nil {
+		if NoExists(err) {
+			log.Printf("[WARN] Removing IP reservation %s from state because its reservation virtual network no longer exists", d.Id())
+			d.SetId("")
+			return nil
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("IP reservation (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	if len(reservation.ParentNetworkID) == 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Not a reservation",
+			Detail:   fmt.Sprintf("virtual network (ID: %s) is not a reservation", d.Id()),
+		})
+		return diags
+	}
+
+	parentID, err := strconv.ParseInt(reservation.ParentNetworkID, 10, 0)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to parse parent network ID",
+			Detail:   fmt.Sprintf("IP reservation (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	// each IP is reserved in its own address range, the reservation is replaced
+	// if one of them has been removed
+	ips := make([]string, 0, len(reservation.ARs))
+	for _, ar := range reservation.ARs {
+		ips = append(ips, ar.IP)
+	}
+
+	if len(ips) == 0 {
+		log.Printf("[WARN] Removing IP reservation %s from state because its address ranges no longer exist", d.Id())
+		d.SetId("")
+		return nil
+	}
+
+	parentARID, err := strconv.ParseInt(reservation.ARs[0].ParentNetworkARID, 10, 0)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to parse parent address range ID",
+			Detail:   fmt.Sprintf("IP reservation (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	d.Set("virtual_network_id", parentID)
+	d.Set("ar_id", parentARID)
+	d.Set("size", len(ips))
+	d.Set("ips", ips)
+	d.Set("reservation_vnet_id", reservation.ID)
+
+	return nil
+}
+
+func resourceOpennebulaVirtualNetworkIPReservationDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	rID, err := strconv.Atoi(d.Id())
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "can't parse IP reservation ID",
+			Detail:   fmt.Sprintf("%s is not an ID: %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	timeout := d.Timeout(schema.TimeoutDelete)
+	vnc := controller.VirtualNetwork(rID)
+
+	// the IPs are still leased by the NICs being detached
+	err = resource.RetryContext(ctx, timeout, func() *resource.RetryError {
+		err = vnc.Delete()
+		if err != nil {
+			if NoExists(err) {
+				return nil
+			}
+			if strings.Contains(err.Error(), "Can not remove a virtual network with leases in use") {
+				return resource.RetryableError(err)
+			}
+			return resource.NonRetryableError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to delete the reservation virtual network",
+			Detail:   fmt.Sprintf("IP reservation (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	transient := []string{vn.Init.String(), vn.Ready.String()}
+	_, err = waitForVNetworkState(ctx, vnc, timeout, transient, []string{"notfound", vn.Done.String()})
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to wait reservation virtual network to be in NOTFOUND or DONE state",
+			Detail:   fmt.Sprintf("IP reservation (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	log.Printf("[INFO] Successfully released IP reservation %s\n", d.Id())
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package opennebula
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestFreeIPv4s(t *testing.T) {
+	used := map[string]bool{
+		"10.0.0.254": true,
+		"10.0.1.0":   true,
+	}
+
+	ips, err := freeIPv4s("10.0.0.253", 5, used, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{"10.0.0.253", "10.0.0.255", "10.0.1.1"}
+	if !reflect.DeepEqual(ips, expected) {
+		t.Fatalf("expected %v, got %v", expected, ips)
+	}
+
+	ips, err = freeIPv4s("10.0.0.253", 5, used, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected = []string{"10.0.0.253", "10.0.0.255"}
+	if !reflect.DeepEqual(ips, expected) {
+		t.Fatalf("expected %v, got %v", expected, ips)
+	}
+
+	_, err = freeIPv4s("2001:db8::1", 5, used, 5)
+	if err == nil {
+		t.Fatalf("expected an error for an IPv6 start address")
+	}
+}
+
+func TestAccVirtualNetworkIPReservation(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVirtualNetworkDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccVirtualNetworkIPReservationConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_virtual_network_ip_reservation.test", "ips.#", "2"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_ip_reservation.test", "ips.0", "172.16.130.100"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_ip_reservation.test", "ips.1", "172.16.130.102"),
+				),
+			},
+			{
+				ResourceName:      "opennebula_virtual_network_ip_reservation.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				Config: testAccVirtualNetworkIPReservationConfig + testAccVirtualNetworkIPReservationVMConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccSetDSdummy(),
+					resource.TestCheckResourceAttr("opennebula_virtual_machine.test", "nic.#", "1"),
+					resource.TestCheckResourceAttrPair("opennebula_virtual_machine.test", "nic.0.computed_ip", "opennebula_virtual_network_ip_reservation.test", "ips.0"),
+					resource.TestCheckResourceAttrPair("opennebula_virtual_machine.test", "nic.0.network_id", "opennebula_virtual_network_ip_reservation.test", "reservation_vnet_id"),
+				),
+			},
+			{
+				Config:      testAccVirtualNetworkIPReservationConfig + testAccVirtualNetworkIPReservationIP6Config,
+				ExpectError: regexp.MustCompile("has no IPv4 address"),
+			},
+		},
+	})
+}
+
+var testAccVirtualNetworkIPReservationConfig = `
+resource "opennebula_virtual_network" "test" {
+	name            = "test-vnet-ip-reservation"
+	type            = "dummy"
+	bridge          = "onebr"
+	mtu             = 1500
+	gateway         = "172.16.130.1"
+	network_mask    = "255.255.255.0"
+	security_groups = [0]
+}
+
+resource "opennebula_virtual_network_address_range" "test" {
+	virtual_network_id = opennebula_virtual_network.test.id
+	ar_type            = "IP4"
+	size               = 16
+	ip4                = "172.16.130.100"
+	hold_ips           = ["172.16.130.101"]
+}
+
+resource "opennebula_virtual_network_ip_reservation" "test" {
+	virtual_network_id = opennebula_virtual_network.test.id
+	ar_id              = opennebula_virtual_network_address_range.test.id
+	size               = 2
+}
+`
+
+var testAccVirtualNetworkIPReservationIP6Config = `
+resource "opennebula_virtual_network_address_range" "ip6" {
+	virtual_network_id = opennebula_virtual_network.test.id
+	ar_type            = "IP6"
+	size               = 2
+}
+
+resource "opennebula_virtual_network_ip_reservation" "ip6" {
+	virtual_network_id = opennebula_virtual_network.test.id
+	ar_id              = opennebula_virtual_network_address_range.ip6.id
+}
+`
+
+var testAccVirtualNetworkIPReservationVMConfig = `
+resource "opennebula_virtual_machine" "test" {
+	name   = "test-vm-ip-reservation"
+	group  = "oneadmin"
+	memory = 128
+	cpu    = 0.1
+
+	nic {
+		network_id = opennebula_virtual_network_ip_reservation.test.reservation_vnet_id
+		ip         = opennebula_virtual_network_ip_reservation.test.ips[0]
+	}
+}
+`
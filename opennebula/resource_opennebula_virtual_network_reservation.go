@@ -0,0 +1,481 @@
+package opennebula
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca"
+	dyn "github.com/OpenNebula/one/src/oca/go/src/goca/dynamic"
+	vn "github.com/OpenNebula/one/src/oca/go/src/goca/schemas/virtualnetwork"
+)
+
+func resourceOpennebulaVirtualNetworkReservation() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceOpennebulaVirtualNetworkReservationCreate,
+		ReadContext:   resourceOpennebulaVirtualNetworkReservationRead,
+		UpdateContext: resourceOpennebulaVirtualNetworkReservationUpdate,
+		DeleteContext: resourceOpennebulaVirtualNetworkReservationDelete,
+		CustomizeDiff: resourceVirtualNetworkReservationCustomizeDiff,
+		Timeouts: &schema.ResourceTimeout{
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceOpennebulaVirtualNetworkReservationImportState,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"virtual_network_id": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the virtual network to reserve the addresses from",
+			},
+			"name": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				ForceNew:      true,
+				Description:   "Name of the reservation virtual network to create",
+				ConflictsWith: []string{"reservation_vnet_id"},
+			},
+			"reservation_vnet_id": {
+				Type:          schema.TypeInt,
+				Optional:      true,
+				ForceNew:      true,
+				Default:       -1,
+				Description:   "ID of an existing reservation virtual network to add the addresses into",
+				ConflictsWith: []string{"name"},
+			},
+			"ar_id": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     -1,
+				Description: "ID of the address range of the virtual network to reserve the addresses from",
+			},
+			"size": {
+				Type:        schema.TypeInt,
+				Required:    true,
+				Description: "Number of addresses to reserve, increasing it reserves more addresses in place",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					if v.(int) < 1 {
+						errors = append(errors, fmt.Errorf("%q must be greater than 0", k))
+					}
+					return
+				},
+			},
+			"first_ip": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "First IPv4 of the reservation",
+			},
+			"first_ip6": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "First IPv6 of the reservation",
+			},
+			"reservation_ar_ids": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "IDs of the address ranges of the reservation virtual network owned by this resource",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+		},
+	}
+}
+
+// resourceVirtualNetworkReservationCustomizeDiff replaces the reservation when it shrinks,
+// addresses can only be added to a reservation
+func resourceVirtualNetworkReservationCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+
+	if diff.Id() == "" || !diff.HasChange("size") {
+		return nil
+	}
+
+	oldSize, newSize := diff.GetChange("size")
+	if newSize.(int) < oldSize.(int) {
+		return diff.ForceNew("size")
+	}
+
+	return nil
+}
+
+// ownsReservationVNet returns true when the resource created the reservation virtual network
+func ownsReservationVNet(d *schema.ResourceData) bool {
+	return d.Get("reservation_vnet_id").(int) == -1
+}
+
+// vnetARIDs returns the IDs of the address ranges of a virtual network
+func vnetARIDs(vnet *vn.VirtualNetwork) map[string]bool {
+	ids := make(map[string]bool, len(vnet.ARs))
+	for _, ar := range vnet.ARs {
+		ids[ar.ID] = true
+	}
+	return ids
+}
+
+// reserveVNetAddresses reserves addresses from the parent virtual network and returns the
+// ID of the reservation virtual network and the IDs of the address ranges added to it
+func reserveVNetAddresses(controller *goca.Controller, parentID int, tpl *dyn.Template, reservationID int) (int, []int, error) {
+
+	knownARs := map[string]bool{}
+	if reservationID > -1 {
+		reservation, err := controller.VirtualNetwork(reservationID).Info(false)
+		if err != nil {
+			return -1, nil, fmt.Errorf("reservation virtual network (ID: %d): %s", reservationID, err)
+		}
+		knownARs = vnetARIDs(reservation)
+		tpl.AddPair("NETWORK_ID", reservationID)
+	}
+
+	rID, err := controller.VirtualNetwork(parentID).Reserve(tpl.String())
+	if err != nil {
+		return -1, nil, err
+	}
+
+	reservation, err := controller.VirtualNetwork(rID).Info(false)
+	if err != nil {
+		return -1, nil, fmt.Errorf("reservation virtual network (ID: %d): %s", rID, err)
+	}
+
+	arIDs := make([]int, 0, 1)
+	for _, ar := range reservation.ARs {
+		if knownARs[ar.ID] {
+			continue
+		}
+		arID, err := strconv.Atoi(ar.ID)
+		if err != nil {
+			return -1, nil, fmt.Errorf("can't parse address range ID %q: %s", ar.ID, err)
+		}
+		arIDs = append(arIDs, arID)
+	}
+
+	return rID, arIDs, nil
+}
+
+func resourceOpennebulaVirtualNetworkReservationCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	parentID := d.Get("virtual_network_id").(int)
+	reservationID := d.Get("reservation_vnet_id").(int)
+
+	tpl := dyn.NewTemplate()
+	tpl.AddPair("SIZE", d.Get("size").(int))
+
+	if name, ok := d.GetOk("name"); ok {
+		tpl.AddPair("NAME", name.(string))
+	} else if reservationID == -1 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Missing reservation name",
+			Detail:   "name is required to create a reservation virtual network, set reservation_vnet_id to reserve into an existing one",
+		})
+		return diags
+	}
+	if arID := d.Get("ar_id").(int); arID > -1 {
+		tpl.AddPair("AR_ID", arID)
+	}
+	if firstIP, ok := d.GetOk("first_ip"); ok {
+		tpl.AddPair("IP", firstIP.(string))
+	}
+	if firstIP6, ok := d.GetOk("first_ip6"); ok {
+		tpl.AddPair("IP6", firstIP6.(string))
+	}
+
+	rID, arIDs, err := reserveVNetAddresses(controller, parentID, tpl, reservationID)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to reserve network addresses",
+			Detail:   fmt.Sprintf("Virtual network (ID: %d) reservation: %s", parentID, err),
+		})
+		return diags
+	}
+
+	d.SetId(fmt.Sprint(rID))
+	d.Set("reservation_ar_ids", arIDs)
+
+	log.Printf("[INFO] Successfully reserved addresses from virtual network %d into %d\n", parentID, rID)
+
+	return resourceOpennebulaVirtualNetworkReservationRead(ctx, d, meta)
+}
+
+func resourceOpennebulaVirtualNetworkReservationRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	rID, err := strconv.ParseInt(d.Id(), 10, 0)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "can't parse virtual network reservation ID",
+			Detail:   fmt.Sprintf("%s is not an ID: %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	reservation, err := controller.VirtualNetwork(int(rID)).Info(false)
+	if err != nil {
+		if NoExists(err) {
+			log.Printf("[WARN] Removing virtual network reservation %s from state because it no longer exists", d.Id())
+			d.SetId("")
+			return nil
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("virtual network reservation (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	if len(reservation.ParentNetworkID) == 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Not a reservation",
+			Detail:   fmt.Sprintf("virtual network (ID: %s) is not a reservation", d.Id()),
+		})
+		return diags
+	}
+
+	parentID, err := strconv.ParseInt(reservation.ParentNetworkID, 10, 0)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to parse parent network ID",
+			Detail:   fmt.Sprintf("virtual network reservation (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+	d.Set("virtual_network_id", parentID)
+
+	// only read the address ranges added by the resource, other resources may
+	// have reserved addresses into the same reservation
+	owned := make(map[string]bool)
+	for _, id := range d.Get("reservation_ar_ids").([]interface{}) {
+		owned[fmt.Sprint(id)] = true
+	}
+
+	if ownsReservationVNet(d) {
+		d.Set("name", reservation.Name)
+	}
+
+	size := 0
+	arIDs := make([]int, 0, len(reservation.ARs))
+	var firstAR *vn.AR
+	for i, ar := range reservation.ARs {
+		if len(owned) > 0 && !owned[ar.ID] {
+			continue
+		}
+
+		arID, err := strconv.Atoi(ar.ID)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to parse address range ID",
+				Detail:   fmt.Sprintf("virtual network reservation (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+
+		if firstAR == nil {
+			firstAR = &reservation.ARs[i]
+		}
+		arIDs = append(arIDs, arID)
+		size += ar.Size
+	}
+
+	if firstAR == nil {
+		log.Printf("[WARN] Removing virtual network reservation %s from state because its address ranges no longer exist", d.Id())
+		d.SetId("")
+		return nil
+	}
+
+	// the address range of the parent is only tracked when configured
+	if d.Get("ar_id").(int) > -1 {
+		parentARID, err := strconv.ParseInt(firstAR.ParentNetworkARID, 10, 0)
+		if err == nil {
+			d.Set("ar_id", parentARID)
+		}
+	}
+	d.Set("first_ip", firstAR.IP)
+	d.Set("first_ip6", firstAR.IP6)
+	d.Set("size", size)
+	d.Set("reservation_ar_ids", arIDs)
+
+	return nil
+}
+
+func resourceOpennebulaVirtualNetworkReservationUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	if d.HasChange("size") {
+		oldSize, newSize := d.GetChange("size")
+
+		parentID := d.Get("virtual_network_id").(int)
+		rID, _ := strconv.Atoi(d.Id())
+
+		tpl := dyn.NewTemplate()
+		tpl.AddPair("SIZE", newSize.(int)-oldSize.(int))
+		if arID := d.Get("ar_id").(int); arID > -1 {
+			tpl.AddPair("AR_ID", arID)
+		}
+
+		_, arIDs, err := reserveVNetAddresses(controller, parentID, tpl, rID)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to extend the reservation",
+				Detail:   fmt.Sprintf("virtual network reservation (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+
+		reservationARIDs := d.Get("reservation_ar_ids").([]interface{})
+		for _, id := range arIDs {
+			reservationARIDs = append(reservationARIDs, id)
+		}
+		d.Set("reservation_ar_ids", reservationARIDs)
+
+		log.Printf("[INFO] Successfully extended virtual network reservation %s\n", d.Id())
+	}
+
+	return resourceOpennebulaVirtualNetworkReservationRead(ctx, d, meta)
+}
+
+func resourceOpennebulaVirtualNetworkReservationDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	rID, err := strconv.Atoi(d.Id())
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "can't parse virtual network reservation ID",
+			Detail:   fmt.Sprintf("%s is not an ID: %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	timeout := d.Timeout(schema.TimeoutDelete)
+
+	// only free the address ranges added into an existing reservation
+	if !ownsReservationVNet(d) {
+		for _, id := range d.Get("reservation_ar_ids").([]interface{}) {
+			err := vNetARRemove(ctx, config.OneVersion, timeout, controller, rID, id.(int))
+			if err != nil && !NoExists(err) {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  "Failed to free the reserved addresses",
+					Detail:   fmt.Sprintf("virtual network reservation (ID: %s) AR (ID: %d): %s", d.Id(), id, err),
+				})
+				return diags
+			}
+		}
+
+		log.Printf("[INFO] Successfully freed address ranges of virtual network reservation %s\n", d.Id())
+		return nil
+	}
+
+	vnc := controller.VirtualNetwork(rID)
+
+	err = resource.RetryContext(ctx, timeout, func() *resource.RetryError {
+		err = vnc.Delete()
+		if err != nil {
+			if NoExists(err) {
+				return nil
+			}
+			if strings.Contains(err.Error(), "Can not remove a virtual network with leases in use") {
+				return resource.RetryableError(err)
+			}
+			return resource.NonRetryableError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to delete the virtual network reservation",
+			Detail:   fmt.Sprintf("virtual network reservation (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	transient := []string{vn.Init.String(), vn.Ready.String()}
+	_, err = waitForVNetworkState(ctx, vnc, timeout, transient, []string{"notfound", vn.Done.String()})
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to wait virtual network reservation to be in NOTFOUND or DONE state",
+			Detail:   fmt.Sprintf("virtual network reservation (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	log.Printf("[INFO] Successfully deleted virtual network reservation %s\n", d.Id())
+	return nil
+}
+
+func resourceOpennebulaVirtualNetworkReservationImportState(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+
+	fullID := d.Id()
+	parts := strings.Split(fullID, ":")
+
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("Invalid ID format. Expected: vnet_id:reservation_vnet_id or vnet_id:reservation_vnet_id:ar_id[,ar_id...]")
+	}
+
+	parentID, err := strconv.ParseInt(parts[0], 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse virtual network ID: %s", err)
+	}
+
+	reservationID, err := strconv.ParseInt(parts[1], 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse reservation virtual network ID: %s", err)
+	}
+
+	d.SetId(parts[1])
+	d.Set("virtual_network_id", parentID)
+	d.Set("reservation_vnet_id", -1)
+
+	// import only some address ranges of the reservation
+	if len(parts) == 3 {
+		arIDs := make([]int, 0)
+		for _, idStr := range strings.Split(parts[2], ",") {
+			arID, err := strconv.ParseInt(idStr, 10, 0)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse address range ID: %s", err)
+			}
+			arIDs = append(arIDs, int(arID))
+		}
+		d.Set("reservation_vnet_id", reservationID)
+		d.Set("reservation_ar_ids", arIDs)
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,95 @@
+package opennebula
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccVirtualNetworkReservation(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVirtualNetworkDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccVirtualNetworkReservationConfig(3, false),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_virtual_network_reservation.test", "name", "test-reservation"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_reservation.test", "size", "3"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_reservation.test", "first_ip", "172.16.140.100"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_reservation.test", "reservation_ar_ids.#", "1"),
+				),
+			},
+			{
+				ResourceName:            "opennebula_virtual_network_reservation.test",
+				ImportState:             true,
+				ImportStateIdFunc:       testAccVirtualNetworkReservationImportID,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"ar_id"},
+			},
+			{
+				Config: testAccVirtualNetworkReservationConfig(5, true),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_virtual_network_reservation.test", "size", "5"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_reservation.test", "reservation_ar_ids.#", "2"),
+					resource.TestCheckResourceAttrPair("opennebula_virtual_network_reservation.extra", "id", "opennebula_virtual_network_reservation.test", "id"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_reservation.extra", "size", "2"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_reservation.extra", "reservation_ar_ids.#", "1"),
+				),
+			},
+		},
+	})
+}
+
+func testAccVirtualNetworkReservationImportID(s *terraform.State) (string, error) {
+	rs, ok := s.RootModule().Resources["opennebula_virtual_network_reservation.test"]
+	if !ok {
+		return "", fmt.Errorf("reservation not found in state")
+	}
+
+	return fmt.Sprintf("%s:%s", rs.Primary.Attributes["virtual_network_id"], rs.Primary.ID), nil
+}
+
+func testAccVirtualNetworkReservationConfig(size int, extra bool) string {
+	config := fmt.Sprintf(`
+resource "opennebula_virtual_network" "test" {
+	name            = "test-vnet-reservation"
+	type            = "dummy"
+	bridge          = "onebr"
+	mtu             = 1500
+	gateway         = "172.16.140.1"
+	network_mask    = "255.255.255.0"
+	security_groups = [0]
+}
+
+resource "opennebula_virtual_network_address_range" "test" {
+	virtual_network_id = opennebula_virtual_network.test.id
+	ar_type            = "IP4"
+	size               = 32
+	ip4                = "172.16.140.100"
+}
+
+resource "opennebula_virtual_network_reservation" "test" {
+	virtual_network_id = opennebula_virtual_network.test.id
+	name               = "test-reservation"
+	ar_id              = opennebula_virtual_network_address_range.test.id
+	size               = %d
+	first_ip           = "172.16.140.100"
+}
+`, size)
+
+	if extra {
+		config += `
+resource "opennebula_virtual_network_reservation" "extra" {
+	virtual_network_id  = opennebula_virtual_network.test.id
+	reservation_vnet_id = opennebula_virtual_network_reservation.test.id
+	size                = 2
+}
+`
+	}
+
+	return config
+}
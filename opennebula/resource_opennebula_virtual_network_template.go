@@ -0,0 +1,616 @@
+package opennebula
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/OpenNebula/one/src/oca/go/src/goca"
+	dyn "github.com/OpenNebula/one/src/oca/go/src/goca/dynamic"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/parameters"
+	"github.com/OpenNebula/one/src/oca/go/src/goca/schemas/shared"
+	vn "github.com/OpenNebula/one/src/oca/go/src/goca/schemas/virtualnetwork"
+	vnk "github.com/OpenNebula/one/src/oca/go/src/goca/schemas/virtualnetwork/keys"
+)
+
+func resourceOpennebulaVirtualNetworkTemplate() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceOpennebulaVirtualNetworkTemplateCreate,
+		ReadContext:   resourceOpennebulaVirtualNetworkTemplateRead,
+		UpdateContext: resourceOpennebulaVirtualNetworkTemplateUpdate,
+		DeleteContext: resourceOpennebulaVirtualNetworkTemplateDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		CustomizeDiff: vnetDriverCustomizeDiff,
+		Schema: mergeSchemas(map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Name of the virtual network template",
+			},
+			"description": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Description of the virtual network template",
+			},
+			"permissions": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "Permissions for the virtual network template (in Unix format, owner-group-other, use-manage-admin)",
+			},
+			"uid": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "ID of the user that will own the virtual network template",
+			},
+			"gid": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "ID of the group that will own the virtual network template",
+			},
+			"uname": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Name of the user that will own the virtual network template",
+			},
+			"gname": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Name of the group that will own the virtual network template",
+			},
+			"group": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Name of the Group that onws the virtual network template, If empty, it uses caller group",
+			},
+			"bridge": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Name of the bridge interface to which the instantiated vnets should be associated",
+			},
+			"physical_device": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Name of the physical device to which the instantiated vnets should be associated",
+			},
+			"type": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "bridge",
+				Description: "Type of the Virtual Network: dummy, bridge, fw, ebtables, 802.1Q, vxlan, ovswitch, ovswitch_vxlan. Default is 'bridge'",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					validtypes := []string{"dummy", "bridge", "fw", "ebtables", "802.1Q", "vxlan", "ovswitch", "ovswitch_vxlan"}
+					value := v.(string)
+
+					if !contains(value, validtypes) {
+						errors = append(errors, fmt.Errorf("Type %q must be one of: %s", k, strings.Join(validtypes, ",")))
+					}
+
+					return
+				},
+			},
+			"cluster_ids": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Description: "List of cluster IDs where the instantiated vnets are created",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"vlan_id": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Description:   "VLAN ID. Only if 'Type' is : 802.1Q, vxlan or ovswich and if 'automatic_vlan_id' is not set",
+				ConflictsWith: []string{"automatic_vlan_id"},
+			},
+			"automatic_vlan_id": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Description:   "If set, let OpenNebula to attribute VLAN ID",
+				ConflictsWith: []string{"vlan_id"},
+			},
+			"mtu": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "MTU of the vnet (defaut: 1500)",
+				Default:     1500,
+			},
+			"guest_mtu": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "MTU of the Guest interface. Must be lower or equal to 'mtu' (defaut: 1500)",
+				Default:     1500,
+			},
+			"gateway": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Gateway IP if necessary",
+			},
+			"network_mask": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Network Mask",
+			},
+			"network_address": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Network Address",
+			},
+			"search_domain": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Search Domain",
+			},
+			"dns": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "DNS IP if necessary",
+			},
+			"ar": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "List of Address Ranges of the instantiated vnets",
+				Elem: &schema.Resource{
+					Schema: ARFields(),
+				},
+			},
+			"security_groups": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Computed:    true,
+				Description: "List of Security Group IDs to be applied to the instantiated vnets",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"lock":             lockSchema(),
+			"tags":             tagsSchema(),
+			"default_tags":     defaultTagsSchemaComputed(),
+			"tags_all":         tagsSchemaComputed(),
+			"template_section": templateSectionSchema(),
+		}, vnetDriverSchemas(nil)),
+	}
+}
+
+func getVirtualNetworkTemplateController(d *schema.ResourceData, meta interface{}) (*goca.VNTemplateController, error) {
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	vntID, err := strconv.ParseUint(d.Id(), 10, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return controller.VNTemplate(int(vntID)), nil
+}
+
+func changeVirtualNetworkTemplateGroup(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	vntc, err := getVirtualNetworkTemplateController(d, meta)
+	if err != nil {
+		return err
+	}
+
+	group := d.Get("group").(string)
+	gid, err := controller.Groups().ByName(group)
+	if err != nil {
+		return fmt.Errorf("can't find a group with name `%s`: %s", group, err)
+	}
+
+	err = vntc.Chown(-1, gid)
+	if err != nil {
+		return fmt.Errorf("can't find a group with ID `%d`: %s", gid, err)
+	}
+
+	return nil
+}
+
+// generateVirtualNetworkTemplate builds the virtual network template content, reusing the
+// virtual network definition and template generation
+func generateVirtualNetworkTemplate(d *schema.ResourceData, meta interface{}) (string, error) {
+
+	vnDef, err := generateVn(d)
+	if err != nil {
+		return "", err
+	}
+
+	vnTpl, err := generateVnTemplate(d, meta)
+	if err != nil {
+		return "", err
+	}
+
+	tpl := vn.NewTemplate()
+
+	for _, arIf := range d.Get("ar").([]interface{}) {
+		ar := vnetGenerateAR(arIf.(map[string]interface{}))
+		tpl.Elements = append(tpl.Elements, &ar.Vector)
+	}
+
+	if securityGroups, ok := d.GetOk("security_groups"); ok {
+		tpl.Add(vnk.SecGroups, ArrayToString(securityGroups.(*schema.Set).List(), ","))
+	}
+
+	if clusterIDs, ok := d.GetOk("cluster_ids"); ok {
+		tpl.AddPair("CLUSTER_IDS", ArrayToString(clusterIDs.(*schema.Set).List(), ","))
+	}
+
+	return strings.Join([]string{vnDef, vnTpl, tpl.String()}, "\n"), nil
+}
+
+func resourceOpennebulaVirtualNetworkTemplateCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Configuration)
+	controller := config.Controller
+
+	var diags diag.Diagnostics
+
+	tplStr, err := generateVirtualNetworkTemplate(d, meta)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to generate description",
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
+	vntID, err := controller.VNTemplates().Create(tplStr)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to create the virtual network template",
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
+	d.SetId(fmt.Sprintf("%v", vntID))
+
+	vntc := controller.VNTemplate(vntID)
+
+	if perms, ok := d.GetOk("permissions"); ok {
+		err = vntc.Chmod(permissionUnix(perms.(string)))
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to change permissions",
+				Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
+	if d.Get("group") != "" {
+		err = changeVirtualNetworkTemplateGroup(d, meta)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to change group",
+				Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
+	if lock, ok := d.GetOk("lock"); ok && lock.(string) != "UNLOCK" {
+
+		var level shared.LockLevel
+		err = StringToLockLevel(lock.(string), &level)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to convert lock level",
+				Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+
+		err = vntc.Lock(level)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to lock",
+				Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
+	return resourceOpennebulaVirtualNetworkTemplateRead(ctx, d, meta)
+}
+
+func resourceOpennebulaVirtualNetworkTemplateRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	var diags diag.Diagnostics
+
+	vntc, err := getVirtualNetworkTemplateController(d, meta)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to get the virtual network template controller",
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
+	vnt, err := vntc.Info(false)
+	if err != nil {
+		if NoExists(err) {
+			log.Printf("[WARN] Removing virtual network template %s from state because it no longer exists in", d.Get("name"))
+			d.SetId("")
+			return nil
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to retrieve informations",
+			Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	d.Set("name", vnt.Name)
+	d.Set("uid", vnt.UID)
+	d.Set("gid", vnt.GID)
+	d.Set("uname", vnt.UName)
+	d.Set("gname", vnt.GName)
+	d.Set("permissions", permissionsUnixString(vnt.Permissions))
+
+	tpl := &vnt.Template.Template
+
+	vnMad, _ := tpl.GetStr(string(vnk.VNMad))
+	d.Set("type", vnMad)
+	bridge, _ := tpl.GetStr(string(vnk.Bridge))
+	d.Set("bridge", bridge)
+	phyDev, _ := tpl.GetStr(string(vnk.PhyDev))
+	d.Set("physical_device", phyDev)
+	vlanID, _ := tpl.GetStr(string(vnk.VlanID))
+	d.Set("vlan_id", vlanID)
+	automaticVlanID, _ := tpl.GetStr("AUTOMATIC_VLAN_ID")
+	d.Set("automatic_vlan_id", automaticVlanID == "YES")
+
+	// the virtual network template content is read as a virtual network content
+	flattenDiags := flattenVnetTemplate(d, meta, false, &vn.Template{Template: *tpl})
+	if len(flattenDiags) > 0 {
+		diags = append(diags, flattenDiags...)
+	}
+
+	err = flattenVnetDriverSchemas(d, &vn.Template{Template: *tpl})
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Failed to flatten driver attributes",
+			Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+		})
+	}
+
+	err = flattenVirtualNetworkTemplateARs(d, tpl)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to flatten address ranges",
+			Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	clusterIDs := make([]int, 0)
+	clusterIDsStr, _ := tpl.GetStr("CLUSTER_IDS")
+	for _, idStr := range strings.Split(clusterIDsStr, ",") {
+		if len(idStr) == 0 {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(idStr))
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to parse cluster ID",
+				Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+		clusterIDs = append(clusterIDs, id)
+	}
+	err = d.Set("cluster_ids", clusterIDs)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to set cluster_ids field",
+			Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	if vnt.LockInfos != nil {
+		d.Set("lock", LockLevelToString(vnt.LockInfos.Locked))
+	}
+
+	return diags
+}
+
+func flattenVirtualNetworkTemplateARs(d *schema.ResourceData, tpl *dyn.Template) error {
+
+	ARList := make([]interface{}, 0)
+
+	for _, ARVec := range tpl.GetVectors("AR") {
+
+		size, err := ARVec.GetInt(string(vnk.Size))
+		if err != nil {
+			return fmt.Errorf("address range size: %s", err)
+		}
+
+		ARMap := map[string]interface{}{
+			"size": size,
+		}
+
+		for k, key := range map[string]string{
+			"ar_type":       string(vnk.Type),
+			"ip4":           string(vnk.IP),
+			"ip6":           "IP6",
+			"mac":           string(vnk.Mac),
+			"global_prefix": string(vnk.GlobalPrefix),
+			"ula_prefix":    string(vnk.UlaPrefix),
+			"prefix_length": string(vnk.PrefixLength),
+		} {
+			value, _ := ARVec.GetStr(key)
+			ARMap[k] = value
+		}
+
+		ARList = append(ARList, ARMap)
+	}
+
+	return d.Set("ar", ARList)
+}
+
+func resourceOpennebulaVirtualNetworkTemplateUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	var diags diag.Diagnostics
+
+	vntc, err := getVirtualNetworkTemplateController(d, meta)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to get the virtual network template controller",
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
+	lock, lockOk := d.GetOk("lock")
+	if d.HasChange("lock") && lockOk && lock.(string) == "UNLOCK" {
+
+		err = vntc.Unlock()
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to unlock",
+				Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
+	if d.HasChange("name") {
+		err = vntc.Rename(d.Get("name").(string))
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to rename",
+				Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
+	// The whole content is regenerated, like at creation
+	if d.HasChangesExcept("name", "permissions", "group", "lock") {
+
+		tplStr, err := generateVirtualNetworkTemplate(d, meta)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to generate description",
+				Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+
+		err = vntc.Update(tplStr, parameters.Replace)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to update content",
+				Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
+	if d.HasChange("permissions") && d.Get("permissions") != "" {
+		if perms, ok := d.GetOk("permissions"); ok {
+			err = vntc.Chmod(permissionUnix(perms.(string)))
+			if err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  "Failed to change permissions",
+					Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+				})
+				return diags
+			}
+		}
+	}
+
+	if d.HasChange("group") {
+		err = changeVirtualNetworkTemplateGroup(d, meta)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to change group",
+				Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
+	if d.HasChange("lock") && lockOk && lock.(string) != "UNLOCK" {
+
+		var level shared.LockLevel
+		err = StringToLockLevel(lock.(string), &level)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to convert lock level",
+				Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+
+		err = vntc.Lock(level)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to lock",
+				Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
+	return resourceOpennebulaVirtualNetworkTemplateRead(ctx, d, meta)
+}
+
+func resourceOpennebulaVirtualNetworkTemplateDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+
+	var diags diag.Diagnostics
+
+	vntc, err := getVirtualNetworkTemplateController(d, meta)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to get the virtual network template controller",
+			Detail:   err.Error(),
+		})
+		return diags
+	}
+
+	err = vntc.Delete()
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to delete",
+			Detail:   fmt.Sprintf("virtual network template (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
+	log.Printf("[INFO] Successfully deleted virtual network template ID %s\n", d.Id())
+
+	return nil
+}
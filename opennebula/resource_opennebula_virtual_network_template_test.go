@@ -0,0 +1,141 @@
+package opennebula
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAccVirtualNetworkTemplate(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVirtualNetworkTemplateDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccVirtualNetworkTemplateConfigBasic,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "name", "vntemplate"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "type", "dummy"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "bridge", "onebr"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "mtu", "1500"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "gateway", "172.16.100.1"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "ar.#", "1"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "ar.0.ar_type", "IP4"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "ar.0.ip4", "172.16.100.110"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "ar.0.size", "16"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "security_groups.#", "1"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "permissions", "642"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "tags.%", "1"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "tags.env", "prod"),
+					resource.TestCheckResourceAttrSet("opennebula_virtual_network_template.test", "uid"),
+					resource.TestCheckResourceAttrSet("opennebula_virtual_network_template.test", "gid"),
+				),
+			},
+			{
+				Config: testAccVirtualNetworkTemplateConfigInstantiate,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "name", "vntemplate-renamed"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network_template.test", "gateway", "172.16.100.254"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "name", "vnet-from-template"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "bridge", "onebr"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "type", "dummy"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "mtu", "1400"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "guest_mtu", "1400"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "gateway", "172.16.100.254"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "dns", "172.16.100.1"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "security_groups.#", "1"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "tags.%", "1"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "tags.customer", "test"),
+					resource.TestCheckResourceAttrPair("opennebula_virtual_network.test", "template_id", "opennebula_virtual_network_template.test", "id"),
+				),
+			},
+			{
+				// the attributes inherited from the template don't diff
+				Config:   testAccVirtualNetworkTemplateConfigInstantiate,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func testAccCheckVirtualNetworkTemplateDestroy(s *terraform.State) error {
+	controller := testAccProvider.Meta().(*Configuration).Controller
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "opennebula_virtual_network_template" {
+			continue
+		}
+
+		vntID, _ := strconv.ParseUint(rs.Primary.ID, 10, 0)
+		vnt, _ := controller.VNTemplate(int(vntID)).Info(false)
+		if vnt != nil {
+			return fmt.Errorf("Expected virtual network template %s to have been destroyed", rs.Primary.ID)
+		}
+	}
+
+	return testAccCheckVirtualNetworkDestroy(s)
+}
+
+var testAccVirtualNetworkTemplateConfigBasic = `
+resource "opennebula_virtual_network_template" "test" {
+  name        = "vntemplate"
+  type        = "dummy"
+  bridge      = "onebr"
+  mtu         = 1500
+  gateway     = "172.16.100.1"
+  dns         = "172.16.100.1"
+  permissions = "642"
+  group       = "oneadmin"
+
+  ar {
+    ar_type = "IP4"
+    size    = 16
+    ip4     = "172.16.100.110"
+  }
+
+  security_groups = [0]
+
+  tags = {
+    env = "prod"
+  }
+}
+`
+
+var testAccVirtualNetworkTemplateConfigInstantiate = `
+resource "opennebula_virtual_network_template" "test" {
+  name        = "vntemplate-renamed"
+  type        = "dummy"
+  bridge      = "onebr"
+  mtu         = 1400
+  guest_mtu   = 1400
+  gateway     = "172.16.100.254"
+  dns         = "172.16.100.1"
+  permissions = "642"
+  group       = "oneadmin"
+
+  ar {
+    ar_type = "IP4"
+    size    = 16
+    ip4     = "172.16.100.110"
+  }
+
+  security_groups = [0]
+
+  tags = {
+    env = "prod"
+  }
+}
+
+resource "opennebula_virtual_network" "test" {
+  name        = "vnet-from-template"
+  template_id = opennebula_virtual_network_template.test.id
+
+  tags = {
+    customer = "test"
+  }
+}
+`
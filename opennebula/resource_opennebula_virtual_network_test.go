@@ -19,6 +19,7 @@ import (
 func TestAccVirtualNetwork(t *testing.T) {
 	networkNotFoundErr, _ := regexp.Compile("Error getting virtual network.*[\n]?.*\\[25\\]")
 	vlanIDConflictError, _ := regexp.Compile(".*\"vlan_id\": conflicts with automatic_vlan_id.*")
+	driverTypeError, _ := regexp.Compile("vxlan block can't be used with a virtual network of type bridge")
 	resource.Test(t, resource.TestCase{
 		PreCheck:     func() { testAccPreCheck(t) },
 		Providers:    testAccProviders,
@@ -146,6 +147,21 @@ func TestAccVirtualNetwork(t *testing.T) {
 					resource.TestCheckTypeSetElemAttr("opennebula_virtual_network.test", "cluster_ids.*", "0"),
 				),
 			},
+			{
+				// without IPAM driver, the address of the range isn't computed
+				Config:             testAccVirtualNetworkConfigRemoveARIP4,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccVirtualNetworkConfigDriverUpdate,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "bridge", "onebr1"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "type", "dummy"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "mtu", "9000"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.test", "guest_mtu", "1500"),
+				),
+			},
 			{
 				Config: testAccVirtualNetworkConfigRemoveGateway,
 				Check: resource.ComposeTestCheckFunc(
@@ -249,6 +265,22 @@ func TestAccVirtualNetwork(t *testing.T) {
 					resource.TestCheckResourceAttr("opennebula_virtual_network.vlan_id_test", "vlan_id", "288"),
 				),
 			},
+			{
+				Config:      testAccVirtualNetworkVXLANOnBridge,
+				ExpectError: driverTypeError,
+			},
+			{
+				Config: testAccVirtualNetworkVXLANAttributes,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("opennebula_virtual_network.vxlan_test", "type", "vxlan"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.vxlan_test", "vxlan.#", "1"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.vxlan_test", "vxlan.0.mode", "evpn"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.vxlan_test", "vxlan.0.tep", "local_ip"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.vxlan_test", "qos.#", "1"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.vxlan_test", "qos.0.inbound_avg_bw", "1000"),
+					resource.TestCheckResourceAttr("opennebula_virtual_network.vxlan_test", "qos.0.outbound_peak_kb", "2048"),
+				),
+			},
 			{
 				Config: testAccVirtualNetworkReservationFirstIP6,
 				Check: resource.ComposeTestCheckFunc(
@@ -527,6 +559,15 @@ var testAccVirtualNetworkConfigUpdate = `
 	}
 `
 
+var testAccVirtualNetworkConfigRemoveARIP4 = strings.Replace(testAccVirtualNetworkConfigUpdate,
+	`ip4                = "172.16.100.170"`, ``, 1)
+
+// testAccVirtualNetworkConfigDriverUpdate changes the bridge and the MTU in place
+var testAccVirtualNetworkConfigDriverUpdate = strings.NewReplacer(
+	`bridge          = "onebr"`, `bridge          = "onebr1"`,
+	`mtu             = 1500`, `mtu             = 9000`,
+).Replace(testAccVirtualNetworkConfigUpdate)
+
 var testAccVirtualNetworkConfigRemoveGateway = `
 	resource "opennebula_virtual_network" "test" {
 	  name = "basic_vnet_gateway"
@@ -734,6 +775,36 @@ var testAccVirtualNetworkVlanIdSetInOVSwitch = `
 	    type        = "ovswitch"
 	}
 `
+var testAccVirtualNetworkVXLANOnBridge = `
+	resource "opennebula_virtual_network" "vxlan_test" {
+	    name = "vxlan_on_bridge"
+	    type = "bridge"
+
+	    vxlan {
+	      mode = "evpn"
+	    }
+	}
+`
+
+var testAccVirtualNetworkVXLANAttributes = `
+	resource "opennebula_virtual_network" "vxlan_test" {
+	    name            = "vxlan_attributes"
+	    type            = "vxlan"
+	    vlan_id         = 300
+	    physical_device = "eth0"
+
+	    vxlan {
+	      mode = "evpn"
+	      tep  = "local_ip"
+	    }
+
+	    qos {
+	      inbound_avg_bw   = 1000
+	      outbound_peak_kb = 2048
+	    }
+	}
+`
+
 var testAccVirtualNetworkReservationFirstIP6 = `
 	resource "opennebula_virtual_network" "basic_vnet_ip6" {
 	  name = "basic_vnet_ip6"
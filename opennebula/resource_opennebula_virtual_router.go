@@ -95,6 +95,8 @@ func resourceOpennebulaVirtualRouter() *schema.Resource {
 				Optional:    true,
 				Description: "A description of the entity",
 			},
+			"keepalived":       vrouterKeepalivedSchema(),
+			"services":         vrouterServicesSchema(),
 			"lock":             lockSchema(),
 			"tags":             tagsSchema(),
 			"default_tags":     defaultTagsSchemaComputed(),
@@ -257,6 +259,16 @@ func resourceOpennebulaVirtualRouterRead(ctx context.Context, d *schema.Resource
 		d.Set("lock", LockLevelToString(vr.LockInfos.Locked))
 	}
 
+	err = flattenVirtualRouterHA(d, &vr.Template.Template)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "virtual router set attribute error",
+			Detail:   fmt.Sprintf("virtual router (ID: %s): %s", d.Id(), err),
+		})
+		return diags
+	}
+
 	flattenDiags := flattenVirtualRouterTemplate(d, meta, &vr.Template)
 	if len(flattenDiags) > 0 {
 		diags = append(diags, flattenDiags...)
@@ -418,13 +430,37 @@ func resourceOpennebulaVirtualRouterUpdate(ctx context.Context, d *schema.Resour
 		log.Printf("[INFO] Successfully updated group for virtual router %s\n", vrInfos.Name)
 	}
 
+	// the template is replaced, start from its current content
 	update := false
-	newTpl := vr.NewTemplate()
+	newTpl := &vrInfos.Template
 	if d.HasChange("description") {
 		update = true
+		newTpl.Del("DESCRIPTION")
 		newTpl.Add("DESCRIPTION", d.Get("description").(string))
 	}
 
+	haUpdate := false
+	oldHAPairs := make(map[string]string)
+	newHAPairs := make(map[string]string)
+	if d.HasChanges("keepalived", "services") {
+
+		oldKeepalived, newKeepalived := d.GetChange("keepalived")
+		oldServices, newServices := d.GetChange("services")
+		oldHAPairs = generateVirtualRouterHA(oldKeepalived.([]interface{}), oldServices.([]interface{}))
+		newHAPairs = generateVirtualRouterHA(newKeepalived.([]interface{}), newServices.([]interface{}))
+
+		for key := range oldHAPairs {
+			newTpl.Del(key)
+		}
+		for key, value := range newHAPairs {
+			newTpl.Del(key)
+			newTpl.AddPair(key, value)
+		}
+
+		update = true
+		haUpdate = true
+	}
+
 	if d.HasChange("template_section") {
 
 		updateTemplateSection(d, &newTpl.Template)
@@ -468,6 +504,21 @@ func resourceOpennebulaVirtualRouterUpdate(ctx context.Context, d *schema.Resour
 		}
 	}
 
+	// OpenNebula only sets the instances context at instantiation
+	if haUpdate {
+		config := meta.(*Configuration)
+
+		err = updateVRouterInstancesContext(config.Controller, vrInfos.VMs.ID, oldHAPairs, newHAPairs)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "virtual router instances context update failed",
+				Detail:   fmt.Sprintf("virtual router (ID: %s): %s", d.Id(), err),
+			})
+			return diags
+		}
+	}
+
 	if d.HasChange("lock") && lockOk && lock.(string) != "UNLOCK" {
 
 		var level shared.LockLevel
@@ -563,8 +614,13 @@ func generateVirtualRouter(d *schema.ResourceData, meta interface{}) string {
 		}
 	}
 
-	tplStr := tpl.String()
-	log.Printf("[INFO] Template definitions: %s", tplStr)
+	log.Printf("[INFO] Template definitions: %s", tpl.String())
+
+	// added after the log as the keepalived password is sensitive
+	haPairs := generateVirtualRouterHA(d.Get("keepalived").([]interface{}), d.Get("services").([]interface{}))
+	for k, v := range haPairs {
+		tpl.AddPair(k, v)
+	}
 
-	return tplStr
+	return tpl.String()
 }
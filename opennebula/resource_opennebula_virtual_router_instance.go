@@ -27,7 +27,7 @@ func resourceOpennebulaVirtualRouterInstance() *schema.Resource {
 		Exists:        resourceOpennebulaVirtualRouterInstanceExists,
 		UpdateContext: resourceOpennebulaVirtualRouterInstanceUpdate,
 		DeleteContext: resourceOpennebulaVirtualRouterInstanceDelete,
-		CustomizeDiff: resourceVMCustomizeDiff,
+		CustomizeDiff: resourceVRInstanceCustomizeDiff,
 		Timeouts: &schema.ResourceTimeout{
 			Create: schema.DefaultTimeout(defaultVMTimeout),
 			Update: schema.DefaultTimeout(defaultVMTimeout),
@@ -45,6 +45,7 @@ func resourceOpennebulaVirtualRouterInstance() *schema.Resource {
 					Required:    true,
 					Description: "Identifier of the parent virtual router ressource",
 				},
+				"user_inputs_values": userInputsValuesSchema(),
 			},
 		),
 	}
@@ -125,6 +126,21 @@ func resourceOpennebulaVirtualRouterInstanceCreate(ctx context.Context, d *schem
 		return diags
 	}
 
+	userInputsValues := d.Get("user_inputs_values").(map[string]interface{})
+	if len(userInputsValues) > 0 {
+		err = applyUserInputsValues(&vmTpl.Template, &tpl.Template.Template, userInputsValues)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Failed to set user inputs values",
+				Detail:   fmt.Sprintf("template (ID: %d): %s", templateID, err),
+			})
+			return diags
+		}
+	}
+
+	addVRouterServicesContext(vmTpl, &vrInfos.Template.Template)
+
 	// The method instantiate for the virtual router doesn't returns the ID of the created VM,
 	// we need to retrieve the VM ID ourselves
 
@@ -378,6 +394,41 @@ func resourceOpennebulaVirtualRouterInstanceCreate(ctx context.Context, d *schem
 	return resourceOpennebulaVirtualRouterInstanceRead(ctx, d, meta)
 }
 
+func resourceVRInstanceCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, v interface{}) error {
+
+	err := resourceVMCustomizeDiff(ctx, diff, v)
+	if err != nil {
+		return err
+	}
+
+	if !diff.NewValueKnown("virtual_router_id") || !diff.NewValueKnown("user_inputs_values") {
+		return nil
+	}
+
+	if diff.Id() != "" && !diff.HasChange("user_inputs_values") {
+		return nil
+	}
+
+	if len(diff.Get("user_inputs_values").(map[string]interface{})) == 0 {
+		return nil
+	}
+
+	controller := v.(*Configuration).Controller
+
+	vRouterID := diff.Get("virtual_router_id").(int)
+	vrInfos, err := controller.VirtualRouter(vRouterID).Info(false)
+	if err != nil {
+		return fmt.Errorf("virtual router (ID: %d): %s", vRouterID, err)
+	}
+
+	templateID, err := vrInfos.Template.GetInt("TEMPLATE_ID")
+	if err != nil {
+		return fmt.Errorf("can't retrieve TEMPLATE_ID tag from virtual router (ID:%d)", vRouterID)
+	}
+
+	return customizeDiffUserInputsValues(diff, controller, templateID)
+}
+
 func resourceOpennebulaVirtualRouterInstanceRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 
 	diags := resourceOpennebulaVirtualMachineReadCustom(ctx, d, meta, func(ctx context.Context, d *schema.ResourceData, vmInfos *vm.VM) diag.Diagnostics {
@@ -94,6 +94,14 @@ func TestAccVirtualRouter(t *testing.T) {
 				Config: testAccVirtualRouterContextUpdate,
 				Check: resource.ComposeTestCheckFunc(
 					resource.TestCheckResourceAttr("opennebula_virtual_router_instance.test2", "context.update_test", "123"),
+					resource.TestCheckResourceAttr("opennebula_virtual_router.test", "keepalived.#", "1"),
+					resource.TestCheckResourceAttr("opennebula_virtual_router.test", "keepalived.0.id", "42"),
+					resource.TestCheckResourceAttr("opennebula_virtual_router.test", "keepalived.0.password", "secret"),
+					resource.TestCheckResourceAttr("opennebula_virtual_router.test", "services.#", "1"),
+					resource.TestCheckResourceAttr("opennebula_virtual_router.test", "services.0.forwarding", "true"),
+					resource.TestCheckResourceAttr("opennebula_virtual_router.test", "services.0.nat", "true"),
+					resource.TestCheckResourceAttr("opennebula_virtual_router.test", "services.0.nat_interfaces_out", "eth0"),
+					resource.TestCheckResourceAttr("opennebula_virtual_router.test", "services.0.dns", "false"),
 					resource.TestCheckResourceAttrSet("opennebula_virtual_router.test", "uid"),
 					resource.TestCheckResourceAttrSet("opennebula_virtual_router.test", "gid"),
 					resource.TestCheckResourceAttrSet("opennebula_virtual_router.test", "uname"),
@@ -241,6 +249,55 @@ func TestAccVirtualRouter(t *testing.T) {
 	})
 }
 
+func TestGenerateVirtualRouterHA(t *testing.T) {
+	keepalived := []interface{}{
+		map[string]interface{}{
+			"id":       42,
+			"password": "secret",
+		},
+	}
+	services := []interface{}{
+		map[string]interface{}{
+			"forwarding":         true,
+			"nat":                false,
+			"nat_interfaces_out": "",
+			"dns":                true,
+			"dns_interfaces":     "eth1",
+			"dhcp":               false,
+			"dhcp_interfaces":    "",
+		},
+	}
+
+	pairs := generateVirtualRouterHA(keepalived, services)
+	expected := map[string]string{
+		"KEEPALIVED_ID":              "42",
+		"KEEPALIVED_PASSWORD":        "secret",
+		"ONEAPP_VNF_ROUTER4_ENABLED": "YES",
+		"ONEAPP_VNF_NAT4_ENABLED":    "NO",
+		"ONEAPP_VNF_DNS_ENABLED":     "YES",
+		"ONEAPP_VNF_DNS_INTERFACES":  "eth1",
+		"ONEAPP_VNF_DHCP4_ENABLED":   "NO",
+	}
+	if !reflect.DeepEqual(pairs, expected) {
+		t.Errorf("unexpected template pairs: %v", pairs)
+	}
+
+	context := vrouterInstanceContext(pairs)
+	if context["VROUTER_KEEPALIVED_ID"] != "42" || context["VROUTER_KEEPALIVED_PASSWORD"] != "secret" {
+		t.Errorf("keepalived settings should be prefixed with VROUTER_ in the context: %v", context)
+	}
+	if _, ok := context["KEEPALIVED_ID"]; ok {
+		t.Errorf("unexpected KEEPALIVED_ID in the context")
+	}
+	if context["ONEAPP_VNF_DNS_INTERFACES"] != "eth1" {
+		t.Errorf("services settings should be kept in the context: %v", context)
+	}
+
+	if len(generateVirtualRouterHA(nil, nil)) != 0 {
+		t.Errorf("no pairs expected without keepalived and services")
+	}
+}
+
 func testAccCheckVirtualRouterDestroy(s *terraform.State) error {
 	config := testAccProvider.Meta().(*Configuration)
 	controller := config.Controller
@@ -509,6 +566,17 @@ resource "opennebula_virtual_router" "test" {
 
     instance_template_id = opennebula_virtual_router_instance_template.test.id
 
+    keepalived {
+        id       = 42
+        password = "secret"
+    }
+
+    services {
+        forwarding         = true
+        nat                = true
+        nat_interfaces_out = "eth0"
+    }
+
     tags = {
         customer = "1"
     }
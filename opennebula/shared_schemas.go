@@ -636,6 +636,93 @@ func descriptionSchema() *schema.Schema {
 	}
 }
 
+func userInputSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:          schema.TypeSet,
+		Optional:      true,
+		ConflictsWith: []string{"user_inputs"},
+		Description:   "Structured definition of the values asked to the user instantiating the template",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Type:        schema.TypeString,
+					Required:    true,
+					Description: "Name of the attribute set with the user value",
+					ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+						value := v.(string)
+
+						if value != strings.ToUpper(value) {
+							errors = append(errors, fmt.Errorf("%q must be in upper case", k))
+						}
+
+						return
+					},
+				},
+				"type": {
+					Type:        schema.TypeString,
+					Required:    true,
+					Description: "Type of the user input",
+					ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+						value := v.(string)
+
+						if !contains(value, userInputTypes) {
+							errors = append(errors, fmt.Errorf("%q must be one of: %s", k, strings.Join(userInputTypes, ", ")))
+						}
+
+						return
+					},
+				},
+				"mandatory": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+					Description: "The user must provide a value",
+				},
+				"description": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "Description displayed to the user",
+				},
+				"options": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					Description: "Values allowed for the list and list-multiple types",
+					Elem: &schema.Schema{
+						Type: schema.TypeString,
+					},
+				},
+				"range_min": {
+					Type:        schema.TypeFloat,
+					Optional:    true,
+					Description: "Minimum value for the range and range-float types",
+				},
+				"range_max": {
+					Type:        schema.TypeFloat,
+					Optional:    true,
+					Description: "Maximum value for the range and range-float types",
+				},
+				"default": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "Default value",
+				},
+			},
+		},
+	}
+}
+
+func userInputsValuesSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeMap,
+		Optional:    true,
+		ForceNew:    true,
+		Description: "Values of the user inputs defined in the template, set at instantiation",
+		Elem: &schema.Schema{
+			Type: schema.TypeString,
+		},
+	}
+}
+
 func makeDiskVector(diskConfig map[string]interface{}) *shared.Disk {
 	disk := shared.NewDisk()
 